@@ -0,0 +1,214 @@
+package cognito
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ALBDataHeader is the header holding the user claims behind an ALB with
+// authentication, see ALB
+const ALBDataHeader = "x-amzn-oidc-data"
+
+// ErrInvalidSigner is returned when the ALB data is not signed by the
+// expected load balancer
+var ErrInvalidSigner = errors.New("token invalid: signer invalid")
+
+// ALB validate the user claims an application load balancer with
+// authentication adds to the requests, in the x-amzn-oidc-data header.
+// It is an ES256 JWT signed with the keys of the region, served by kid as
+// PEM instead of a jwks. The keys are cached the same way as for Auth.
+// see https://docs.aws.amazon.com/elasticloadbalancing/latest/application/listener-authenticate-users.html#user-claims-encoding
+type ALB struct {
+	auth   *Auth
+	signer string
+}
+
+// ALBPayload structure containing the payload of the ALB data. Claims has
+// all the claims of the user info endpoint of the identity provider
+type ALBPayload struct {
+	Sub      string    `mapstructure:"sub"`
+	Email    string    `mapstructure:"email"`
+	Username string    `mapstructure:"username"`
+	Iss      string    `mapstructure:"iss"`
+	Exp      time.Time `mapstructure:"exp"`
+	// Signer and Client come from the token header: the arn of the load
+	// balancer and the app client id
+	Signer string                 `mapstructure:"-"`
+	Client string                 `mapstructure:"-"`
+	Claims map[string]interface{} `mapstructure:"-"`
+}
+
+// NewALB create a validator of the ALB data for the load balancers of region.
+// signer is the arn of the load balancer, the data signed by an other one is
+// rejected with ErrInvalidSigner. It should only be empty when the header
+// can not be forged, as any load balancer could sign it.
+// The keys are not a jwks: WithALBKeysURL override their base url, and
+// WithJWKS or WithJWKSURL is an error
+func NewALB(region, signer string, opts ...Option) (*ALB, error) {
+	aws := New(region, "", opts...)
+	if aws.jwks != nil || aws.jwksURL != "" {
+		return nil, errors.New("the ALB keys are not a jwks, use WithALBKeysURL instead of WithJWKS or WithJWKSURL")
+	}
+	baseURL := fmt.Sprintf("https://public-keys.auth.elb.%s.amazonaws.com", region)
+	if aws.albKeysURL != "" {
+		baseURL = strings.TrimSuffix(aws.albKeysURL, "/")
+	}
+	aws.keyURL = func(kid string) string {
+		return baseURL + "/" + url.PathEscape(kid)
+	}
+	return &ALB{auth: aws, signer: signer}, nil
+}
+
+// Validate validate the value of the x-amzn-oidc-data header
+func (alb *ALB) Validate(data string, opts ...ValidateOption) (*ALBPayload, error) {
+	return alb.ValidateContext(context.Background(), data, opts...)
+}
+
+// ValidateContext is similar as Validate, see ValidateTokenContext
+func (alb *ALB) ValidateContext(ctx context.Context, data string, opts ...ValidateOption) (*ALBPayload, error) {
+	ctx, span := alb.auth.tracer().Start(ctx, "cognito.ValidateALBData")
+	defer span.End()
+	if alb.signer != "" {
+		opts = append([]ValidateOption{withSigner(alb.signer)}, opts...)
+	}
+	token, claims, err := alb.auth.parse(ctx, data, opts)
+	alb.auth.observeValidation(ctx, token, err)
+	if err != nil {
+		return nil, err
+	}
+	rawValues := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		rawValues[k] = v
+	}
+	convertTimeStamp(rawValues)
+	values := &ALBPayload{}
+	if err := mapstructure.Decode(rawValues, values); err != nil {
+		return nil, err
+	}
+	values.Claims = claims
+	values.Signer, _ = token.Header["signer"].(string)
+	values.Client, _ = token.Header["client"].(string)
+	return values, nil
+}
+
+// ValidateRequest validate the x-amzn-oidc-data header of r
+func (alb *ALB) ValidateRequest(r *http.Request, opts ...ValidateOption) (*ALBPayload, error) {
+	return alb.ValidateContext(r.Context(), r.Header.Get(ALBDataHeader), opts...)
+}
+
+// withSigner check the signer header, the arn of the load balancer
+func withSigner(signer string) ValidateOption {
+	return func(token TokenInfo, claims map[string]interface{}) error {
+		if got, _ := token.Header["signer"].(string); got != signer {
+			return ErrInvalidSigner
+		}
+		return nil
+	}
+}
+
+// loadKey fetch the key kid and add it to the cached keys. Unlike the jwks,
+// the keys served by kid never change so the other keys are kept
+func (aws *Auth) loadKey(ctx context.Context, kid string) error {
+	keyURL := aws.keyURL(kid)
+	start := time.Now()
+	key, err := aws.fetchKey(ctx, keyURL, kid)
+	aws.metricsHook().ObserveFetch(time.Since(start), err)
+	if err != nil {
+		aws.logFetchError(ctx, keyURL, err)
+		return &fetchError{err}
+	}
+	if key == nil {
+		// the kid is not found
+		return nil
+	}
+	if err := aws.checkKey(key); err != nil {
+		aws.logKeyRejected(ctx, kid, err)
+		return nil
+	}
+	aws.awsKeysLock.Lock()
+	previous := aws.awsKeys
+	loaded := make(map[string]*awsWellKnowKey, len(previous)+1)
+	for k, v := range previous {
+		loaded[k] = v
+	}
+	loaded[kid] = key
+	aws.awsKeys = loaded
+	aws.awsKeysLock.Unlock()
+	aws.metricsHook().ObserveCacheSize(len(loaded))
+	aws.logKeysLoaded(ctx, keyURL, previous, loaded)
+	if aws.hooks.OnKeysRefreshed != nil {
+		aws.hooks.OnKeysRefreshed(keyInfos(previous), keyInfos(loaded))
+	}
+	return nil
+}
+
+// fetchKey fetch a PEM encoded EC public key, it returns nil when the kid
+// is not found
+func (aws *Auth) fetchKey(ctx context.Context, keyURL, kid string) (key *awsWellKnowKey, err error) {
+	ctx, span := aws.tracer().Start(ctx, "cognito.fetchKeys")
+	defer func() {
+		recordError(span, err)
+		span.End()
+	}()
+	req, err := http.NewRequest(http.MethodGet, keyURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fail to create key request: %w", err)
+	}
+	resp, err := aws.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("fail to fetch key: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusForbidden:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("fail to fetch key: unexpected status %d", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read key body: %w", err)
+	}
+	return parsePEMKey(kid, body)
+}
+
+// parsePEMKey parse a PEM encoded EC public key, the alg is the one of the curve
+func parsePEMKey(kid string, body []byte) (*awsWellKnowKey, error) {
+	block, _ := pem.Decode(body)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("kid: %s is malformed: no PEM public key", kid)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("kid: %s is malformed: %w", kid, err)
+	}
+	ecKey, ok := publicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("kid: %s is malformed: not an EC key", kid)
+	}
+	crv := ecKey.Curve.Params().Name
+	var alg string
+	switch crv {
+	case "P-256":
+		alg = "ES256"
+	case "P-384":
+		alg = "ES384"
+	case "P-521":
+		alg = "ES512"
+	default:
+		return nil, fmt.Errorf("kid: %s is malformed: curve %s is not supported", kid, crv)
+	}
+	return &awsWellKnowKey{Kid: kid, Alg: alg, Kty: "EC", Use: "sig", Crv: crv, publicKey: ecKey}, nil
+}
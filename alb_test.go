@@ -0,0 +1,215 @@
+package cognito
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const testALBSigner = "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/my-alb/50dc6c495c0c9188"
+
+// newTestALBKeys serve the PEM public keys by kid, like the ALB public keys
+// endpoint, and count the requests
+func newTestALBKeys(t *testing.T, keys map[string]interface{}) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.EscapedPath())
+		mu.Unlock()
+		key, ok := keys[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.Error(w, "not found", http.StatusForbidden)
+			return
+		}
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests...)
+	}
+}
+
+// testALBData sign the data as an ALB does, the header has the signer and
+// the client besides the kid
+func testALBData(kid, signer string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+	token.Header["signer"] = signer
+	token.Header["client"] = "16p6m803hdmmvqs0bbvinfb9pt"
+	token.Header["iss"] = "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_TEST00000"
+	data, err := token.SignedString(testECKey)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// testALBPaddedData is similar as testALBData, but every segment keeps its
+// base64 padding as the ALB does
+func testALBPaddedData(kid, signer string, claims jwt.MapClaims) string {
+	parts := strings.Split(testALBData(kid, signer, claims), ".")[:2]
+	for i := range parts {
+		parts[i] = padSegment(parts[i])
+	}
+	signingString := strings.Join(parts, ".")
+	signature, err := jwt.SigningMethodES256.Sign(signingString, testECKey)
+	if err != nil {
+		panic(err)
+	}
+	// an ES256 signature is 64 bytes, so its segment is always padded
+	return signingString + "." + padSegment(signature)
+}
+
+func padSegment(segment string) string {
+	if n := len(segment) % 4; n > 0 {
+		segment += strings.Repeat("=", 4-n)
+	}
+	return segment
+}
+
+func TestNewALB(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantURL string
+		wantErr bool
+	}{
+		{"should use the keys of the region", nil, "https://public-keys.auth.elb.eu-west-1.amazonaws.com/kid", false},
+		{"should use the keys url", []Option{WithALBKeysURL("http://127.0.0.1:8080/keys/")}, "http://127.0.0.1:8080/keys/kid", false},
+		{"should return error: jwks url", []Option{WithJWKSURL("http://127.0.0.1:8080/keys")}, "", true},
+		{"should return error: jwks", []Option{WithJWKS([]byte(`{"keys":[]}`))}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewALB("eu-west-1", testALBSigner, tt.opts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewALB() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.auth.keyURL("kid") != tt.wantURL {
+				t.Errorf("NewALB() key url = %v, want %v", got.auth.keyURL("kid"), tt.wantURL)
+			}
+		})
+	}
+}
+
+func TestALB_Validate(t *testing.T) {
+	kid := "4f3c2a1b-6d5e-4c3b-9a8f-7e6d5c4b3a21"
+	srv, requests := newTestALBKeys(t, map[string]interface{}{
+		kid:      &testECKey.PublicKey,
+		"rsa":    &testRSAKey.PublicKey,
+		"a/b":    &testECKey.PublicKey,
+		"ec-384": &testEC384Key.PublicKey,
+	})
+	exp := time.Now().Add(time.Minute).Truncate(time.Second)
+	claims := jwt.MapClaims{
+		"sub":            "3a9f2f3a-e659-41da-b116-0902d1f7d4ea",
+		"email":          "test-user@example.com",
+		"email_verified": "true",
+		"username":       "test-user",
+		"exp":            exp.Unix(),
+		"iss":            "https://cognito-idp.us-east-1.amazonaws.com/us-east-1_TEST00000",
+	}
+	alb, err := NewALB("us-east-1", testALBSigner, WithALBKeysURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should return the payload", func(t *testing.T) {
+		got, err := alb.Validate(testALBData(kid, testALBSigner, claims))
+		if err != nil {
+			t.Fatalf("ALB.Validate() error = %v", err)
+		}
+		if got.Sub != claims["sub"] || got.Email != "test-user@example.com" || got.Username != "test-user" || !got.Exp.Equal(exp) ||
+			got.Signer != testALBSigner || got.Client != "16p6m803hdmmvqs0bbvinfb9pt" || got.Claims["email_verified"] != "true" {
+			t.Errorf("ALB.Validate() = %+v", got)
+		}
+	})
+
+	t.Run("should fetch each key once", func(t *testing.T) {
+		before := len(requests())
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				alb.Validate(testALBData(kid, testALBSigner, claims))
+			}()
+		}
+		wg.Wait()
+		if got := len(requests()) - before; got != 0 {
+			t.Errorf("the keys were fetched %v times, want 0", got)
+		}
+	})
+
+	t.Run("should keep the other keys", func(t *testing.T) {
+		if _, err := alb.Validate(testALBData("a/b", testALBSigner, claims)); err != nil {
+			t.Fatalf("ALB.Validate() error = %v", err)
+		}
+		if got := requests(); got[len(got)-1] != "/a%2Fb" {
+			t.Errorf("the key was fetched at %v, want /a%%2Fb", got[len(got)-1])
+		}
+		if len(alb.auth.awsKeys) != 2 {
+			t.Errorf("cached keys = %v, want 2", len(alb.auth.awsKeys))
+		}
+	})
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{"should accept the padded segments of the ALB", testALBPaddedData(kid, testALBSigner, claims), nil},
+		{"should return error: other signer", testALBData(kid, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/other/1", claims), ErrInvalidSigner},
+		{"should return error: unknown kid", testALBData("unknown", testALBSigner, claims), ErrKidNotFound},
+		{"should return error: not an EC key", testALBData("rsa", testALBSigner, claims), errFetch},
+		{"should return error: key of an other curve", testALBData("ec-384", testALBSigner, claims), ErrInvalidAlg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := alb.Validate(tt.data)
+			if tt.wantErr == errFetch {
+				var fe *fetchError
+				if !errors.As(err, &fe) {
+					t.Errorf("ALB.Validate() error = %v, want fetch error", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ALB.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("should return error: expired", func(t *testing.T) {
+		expired := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": time.Now().Add(-time.Minute).Unix()}
+		if _, err := alb.Validate(testALBData(kid, testALBSigner, expired)); outcome(err) != "expired" {
+			t.Errorf("ALB.Validate() error = %v, want expired", err)
+		}
+	})
+
+	t.Run("should validate the header of the request", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set(ALBDataHeader, testALBData(kid, testALBSigner, claims))
+		if _, err := alb.ValidateRequest(r); err != nil {
+			t.Errorf("ALB.ValidateRequest() error = %v", err)
+		}
+	})
+}
+
+// errFetch mark the test cases expecting a *fetchError
+var errFetch = errors.New("fetch error")
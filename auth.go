@@ -1,17 +1,55 @@
 package cognito
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"log/slog"
 	"math/big"
+	"net/http"
+	"sort"
 	"sync"
 	"time"
 
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/mitchellh/mapstructure"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Errors returned by the validation, the other errors come from the jwt
+// parsing and are *jwt.ValidationError
+var (
+	// ErrNoKid is returned when the token header has no kid
+	ErrNoKid = errors.New("token invalid: no kid")
+	// ErrKidNotFound is returned when the kid is not in the jwks, even after a refresh
+	ErrKidNotFound = errors.New("kid is not found")
+	// ErrInvalidAlg is returned when the token alg can not be used with the key
+	ErrInvalidAlg = errors.New("token invalid: alg invalid")
+	// ErrInvalidIssuer is returned when the token iss does not match the OIDC issuer
+	ErrInvalidIssuer = errors.New("token invalid: iss invalid")
+	// ErrInvalidNonce is returned when the nonce does not match, see WithNonce
+	ErrInvalidNonce = errors.New("token invalid: nonce invalid")
+	// ErrInvalidAtHash is returned when the at_hash does not match, see WithAccessTokenHash
+	ErrInvalidAtHash = errors.New("token invalid: at_hash invalid")
+	// ErrAuthTooOld is returned when the user authenticated too long ago, see
+	// WithMaxAuthAge. The user should be sent to authenticate again
+	ErrAuthTooOld = errors.New("token invalid: auth_time is too old")
+	// ErrInvalidClientID is returned when the client_id is not allowed, see WithClientID
+	ErrInvalidClientID = errors.New("token invalid: client_id invalid")
+	// ErrInsufficientScope is returned when a required scope is missing, see WithScopes
+	ErrInsufficientScope = errors.New("token invalid: insufficient scope")
+	// ErrGroupNotAllowed is returned when the user is in none of the groups, see WithGroups
+	ErrGroupNotAllowed = errors.New("token invalid: group not allowed")
+	// ErrNotM2MToken is returned by ValidateM2MToken for a token issued to a user
+	ErrNotM2MToken = errors.New("token invalid: not a client credentials token")
 )
 
 // Auth is the main structure that contains all the methods for
@@ -25,56 +63,154 @@ type Auth struct {
 	region      string
 	userPoolID  string
 	awsKeysLock sync.RWMutex
+
+	// jwksURL override the cognito jwks url, see NewOIDC
+	jwksURL string
+	// jwks is a static jwks document used instead of fetching one
+	jwks []byte
+	// keyURL is set when the keys are fetched one by one by kid instead of
+	// in a jwks, see NewALB
+	keyURL func(kid string) string
+	// albKeysURL override the base url of keyURL, see WithALBKeysURL
+	albKeysURL string
+	// issuer and algorithms are only set for generic OIDC issuers
+	issuer         string
+	algorithms     []string
+	httpClient     *http.Client
+	metrics        Metrics
+	tracerProvider trace.TracerProvider
+	logger         *slog.Logger
+	hooks          Hooks
+	// minRSAKeySize is the minimum size of the RSA keys, DefaultMinRSAKeySize when 0
+	minRSAKeySize int
+	// batchConcurrency is the number of tokens of a batch verified in
+	// parallel, see ValidateBatch
+	batchConcurrency int
 }
 
 // New is a simple constructor of the main structure.
 // It needs the region and the userPoolID in order to validate correctly
 // the jwt token
-func New(region, userPoolID string) *Auth {
-	return &Auth{
+func New(region, userPoolID string, opts ...Option) *Auth {
+	aws := &Auth{
 		region:     region,
 		userPoolID: userPoolID,
 		awsKeys:    map[string]*awsWellKnowKey{},
 	}
+	for _, opt := range opts {
+		opt(aws)
+	}
+	return aws
 }
 
 // ValidateToken is a generic method that validate a JWT token
 // and return the raw payload
-func (aws *Auth) ValidateToken(tokenString string) (map[string]interface{}, error) {
+func (aws *Auth) ValidateToken(tokenString string, opts ...ValidateOption) (map[string]interface{}, error) {
+	return aws.ValidateTokenContext(context.Background(), tokenString, opts...)
+}
+
+// ValidateTokenContext is similar as ValidateToken, ctx is used to fetch the
+// jwks and as parent of the trace spans
+func (aws *Auth) ValidateTokenContext(ctx context.Context, tokenString string, opts ...ValidateOption) (map[string]interface{}, error) {
+	return aws.validateWithKeys(ctx, tokenString, opts, aws.getAwsKey)
+}
+
+// validateWithKeys is similar as ValidateTokenContext, the key of the token is
+// found with getKey
+func (aws *Auth) validateWithKeys(ctx context.Context, tokenString string, opts []ValidateOption, getKey keyFunc) (map[string]interface{}, error) {
+	ctx, span := aws.tracer().Start(ctx, "cognito.ValidateToken")
+	defer span.End()
+	token, claims, err := aws.parseWithKeys(ctx, tokenString, opts, getKey)
+	aws.observeValidation(ctx, token, err)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// parse validate the token. The token is returned even when it is not valid,
+// as long as it could be decoded
+func (aws *Auth) parse(ctx context.Context, tokenString string, opts []ValidateOption) (*jwt.Token, jwt.MapClaims, error) {
+	return aws.parseWithKeys(ctx, tokenString, opts, aws.getAwsKey)
+}
+
+// keyFunc return the key of a kid, see getAwsKey
+type keyFunc func(ctx context.Context, kid string) (*awsWellKnowKey, error)
+
+// parseWithKeys is similar as parse, the key of the token is found with getKey
+func (aws *Auth) parseWithKeys(ctx context.Context, tokenString string, opts []ValidateOption, getKey keyFunc) (*jwt.Token, jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		kid, ok := token.Header["kid"].(string)
 		if !ok {
-			return nil, errors.New("token invalid: no kid")
+			return nil, ErrNoKid
 		}
-		awsKey, err := aws.getAwsKey(kid)
+		awsKey, err := getKey(ctx, kid)
 		if err != nil {
 			return nil, err
 		}
 
-		if token.Method.Alg() != awsKey.Alg {
-			return nil, errors.New("token invalid: alg invalid")
+		// alg is optional in a jwk, when it is missing the key type is
+		// still checked against the token method below
+		if awsKey.Alg != "" && token.Method.Alg() != awsKey.Alg {
+			return nil, ErrInvalidAlg
+		}
+		if len(aws.algorithms) > 0 && !containsString(aws.algorithms, token.Method.Alg()) {
+			return nil, fmt.Errorf("%w: not supported by the issuer", ErrInvalidAlg)
 		}
 
-		return getPublicKey(awsKey.E, awsKey.N)
+		if awsKey.publicKey == nil {
+			return nil, errors.New("token invalid: public key not loaded")
+		}
+		if err := checkSigningKey(token.Method, awsKey.publicKey); err != nil {
+			return nil, err
+		}
+		return awsKey.publicKey, nil
 	})
 
+	if ve, ok := err.(*jwt.ValidationError); ok && ve.Inner != nil && ve.Errors&jwt.ValidationErrorUnverifiable != 0 {
+		// the error comes from the key func, return it directly so it can
+		// be checked with errors.Is. The message is the same
+		err = ve.Inner
+	}
 	if err != nil {
-		return nil, err
+		return token, nil, err
 	}
 
 	claim, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return nil, errors.New("token not valid")
+		return token, nil, errors.New("token not valid")
+	}
+	if aws.issuer != "" && !claim.VerifyIssuer(aws.issuer, true) {
+		return token, nil, ErrInvalidIssuer
 	}
+	info := TokenInfo{Alg: token.Method.Alg(), Header: token.Header}
+	for _, opt := range opts {
+		if err := opt(info, claim); err != nil {
+			return token, nil, err
+		}
+	}
+
+	return token, claim, nil
+}
 
-	return claim, nil
+// ValidateIDTokenWithNonce is similar as ValidateIDToken but also check that
+// the nonce claim is the one sent in the authorization request.
+// Use WithAccessTokenHash in opts to also check the at_hash claim
+func (aws *Auth) ValidateIDTokenWithNonce(IDToken, nonce string, opts ...ValidateOption) (*IDTokenPayload, error) {
+	opts = append([]ValidateOption{WithNonce(nonce)}, opts...)
+	return aws.ValidateIDToken(IDToken, opts...)
 }
 
 // ValidateAccessToken accessToken is an helper to validate the accessToken
 // see https://docs.aws.amazon.com/cognito/latest/developerguide/amazon-cognito-user-pools-using-tokens-with-identity-providers.html
 // It is similar as ValidateToken but will return a real structure
-func (aws *Auth) ValidateAccessToken(accessToken string) (*AccessTokenPayload, error) {
-	rawValues, err := aws.ValidateToken(accessToken)
+func (aws *Auth) ValidateAccessToken(accessToken string, opts ...ValidateOption) (*AccessTokenPayload, error) {
+	return aws.ValidateAccessTokenContext(context.Background(), accessToken, opts...)
+}
+
+// ValidateAccessTokenContext is similar as ValidateAccessToken, see ValidateTokenContext
+func (aws *Auth) ValidateAccessTokenContext(ctx context.Context, accessToken string, opts ...ValidateOption) (*AccessTokenPayload, error) {
+	rawValues, err := aws.ValidateTokenContext(ctx, accessToken, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -89,12 +225,18 @@ func (aws *Auth) ValidateAccessToken(accessToken string) (*AccessTokenPayload, e
 // ValidateIDToken accessToken is an helper to validate the IDToken
 // see https://docs.aws.amazon.com/cognito/latest/developerguide/amazon-cognito-user-pools-using-tokens-with-identity-providers.html
 // It is similar as ValidateToken but will return a real structure
-func (aws *Auth) ValidateIDToken(IDToken string) (*IDTokenPayload, error) {
-	rawValues, err := aws.ValidateToken(IDToken)
+func (aws *Auth) ValidateIDToken(IDToken string, opts ...ValidateOption) (*IDTokenPayload, error) {
+	return aws.ValidateIDTokenContext(context.Background(), IDToken, opts...)
+}
+
+// ValidateIDTokenContext is similar as ValidateIDToken, see ValidateTokenContext
+func (aws *Auth) ValidateIDTokenContext(ctx context.Context, IDToken string, opts ...ValidateOption) (*IDTokenPayload, error) {
+	rawValues, err := aws.ValidateTokenContext(ctx, IDToken, opts...)
 	if err != nil {
 		return nil, err
 	}
 	convertTimeStamp(rawValues)
+	convertIdentities(rawValues)
 	values := &IDTokenPayload{}
 	if err := mapstructure.Decode(rawValues, values); err != nil {
 		return nil, err
@@ -102,33 +244,128 @@ func (aws *Auth) ValidateIDToken(IDToken string) (*IDTokenPayload, error) {
 	return values, nil
 }
 
-func (aws *Auth) getAwsKey(k string) (*awsWellKnowKey, error) {
+func (aws *Auth) getAwsKey(ctx context.Context, k string) (*awsWellKnowKey, error) {
 	aws.awsKeysLock.RLock()
 	v, ok := aws.awsKeys[k]
 	aws.awsKeysLock.RUnlock()
+	trace.SpanFromContext(ctx).SetAttributes(attrKid.String(k), attrCacheHit.Bool(ok))
 	// kind of lazy loading. If the key is not present we will refresh
 	// we might need to add mutex to be safe later...
 	if !ok {
-		keys, err := aws.fetchKeys()
-		if err != nil {
-			return nil, err
+		load := aws.loadKeys
+		if aws.keyURL != nil {
+			load = func(ctx context.Context) error { return aws.loadKey(ctx, k) }
 		}
-		aws.awsKeysLock.Lock()
-		for _, key := range keys {
-			aws.awsKeys[key.Kid] = key
+		if err := load(ctx); err != nil {
+			return nil, err
 		}
-		aws.awsKeysLock.Unlock()
 	}
 
 	aws.awsKeysLock.RLock()
 	v, ok = aws.awsKeys[k]
 	aws.awsKeysLock.RUnlock()
 	if !ok {
-		return nil, errors.New("kid: " + k + " is not found")
+		if aws.hooks.OnUnknownKid != nil {
+			aws.hooks.OnUnknownKid(k)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrKidNotFound, k)
 	}
 	return v, nil
 }
 
+// loadKeys fetch the jwks and replace the cached keys, so a key removed
+// from the jwks can not be used anymore. A jwks without any usable key is
+// an error and the cached keys are kept, so a bad refresh can not reject
+// every token
+func (aws *Auth) loadKeys(ctx context.Context) error {
+	start := time.Now()
+	keys, err := aws.fetchKeys(ctx)
+	infos, loaded := aws.checkKeys(keys)
+	for _, info := range infos {
+		if info.Err != nil {
+			aws.logKeyRejected(ctx, info.Kid, info.Err)
+		}
+	}
+	if err == nil && len(loaded) == 0 {
+		err = errNoUsableKey
+	}
+	aws.metricsHook().ObserveFetch(time.Since(start), err)
+	if err != nil {
+		aws.logFetchError(ctx, aws.keysURL(), err)
+		return &fetchError{err}
+	}
+	aws.awsKeysLock.Lock()
+	previous := aws.awsKeys
+	aws.awsKeys = loaded
+	aws.awsKeysLock.Unlock()
+	aws.metricsHook().ObserveCacheSize(len(loaded))
+	aws.logKeysLoaded(ctx, aws.keysURL(), previous, loaded)
+	if aws.hooks.OnKeysRefreshed != nil && !sameKeys(previous, loaded) {
+		aws.hooks.OnKeysRefreshed(keyInfos(previous), keyInfos(loaded))
+	}
+	return nil
+}
+
+// errNoUsableKey is returned when every key of the jwks is rejected
+var errNoUsableKey = errors.New("jwks has no usable key")
+
+// checkKeys parse and check the keys of a jwks. It return the description
+// of every key sorted by kid, with the reason it is rejected if any, and the
+// keys that can be used to validate a token
+func (aws *Auth) checkKeys(keys []*awsWellKnowKey) ([]Key, map[string]*awsWellKnowKey) {
+	infos := make([]Key, 0, len(keys))
+	usable := make(map[string]*awsWellKnowKey, len(keys))
+	for _, key := range keys {
+		// a malformed key is rejected here so it can never be used
+		// to validate a token
+		err := key.parsePublicKey()
+		if err == nil {
+			err = aws.checkKey(key)
+		}
+		info := key.info()
+		if err != nil {
+			info.Err = err
+		} else {
+			usable[key.Kid] = key
+		}
+		infos = append(infos, info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool { return infos[i].Kid < infos[j].Kid })
+	return infos, usable
+}
+
+// Keys fetch the jwks and return all its keys sorted by kid. The keys that
+// can not be used to validate a token are also returned, with the reason
+// in Err. It only lists the keys: the cached keys are left untouched and
+// no metrics, logs or hooks are reported
+func (aws *Auth) Keys() ([]Key, error) {
+	keys, err := aws.fetchKeys(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	infos, _ := aws.checkKeys(keys)
+	return infos, nil
+}
+
+// keyInfos return the description of the keys, sorted by kid
+func keyInfos(awsKeys map[string]*awsWellKnowKey) []Key {
+	keys := make([]Key, 0, len(awsKeys))
+	for _, key := range awsKeys {
+		keys = append(keys, key.info())
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Kid < keys[j].Kid })
+	return keys
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // later need to move to mapstruct if possible
 func convertTimeStamp(raw map[string]interface{}) {
 	expTime := getTimeStamp(raw["exp"])
@@ -143,6 +380,10 @@ func convertTimeStamp(raw map[string]interface{}) {
 	if authTime != nil {
 		raw["auth_time"] = authTime
 	}
+	updatedAt := getTimeStamp(raw["updated_at"])
+	if updatedAt != nil {
+		raw["updated_at"] = updatedAt
+	}
 }
 
 func getTimeStamp(raw interface{}) *time.Time {
@@ -155,9 +396,25 @@ func getTimeStamp(raw interface{}) *time.Time {
 	return &tm
 }
 
+// checkSigningKey ensure that the token method can be used with the public key,
+// for example an ES256 token can only be verified with a P-256 key
+func checkSigningKey(method jwt.SigningMethod, publicKey crypto.PublicKey) error {
+	switch m := method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		if _, ok := publicKey.(*rsa.PublicKey); ok {
+			return nil
+		}
+	case *jwt.SigningMethodECDSA:
+		if k, ok := publicKey.(*ecdsa.PublicKey); ok && k.Curve.Params().BitSize == m.CurveBits {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: does not match the key type", ErrInvalidAlg)
+}
+
 func getPublicKey(rawE, rawN string) (*rsa.PublicKey, error) {
 	decodedE, err := base64.RawURLEncoding.DecodeString(rawE)
-	if err != nil {
+	if err != nil || len(decodedE) == 0 {
 		return nil, errors.New("public key exponent is invalid")
 	}
 
@@ -173,7 +430,7 @@ func getPublicKey(rawE, rawN string) (*rsa.PublicKey, error) {
 	e := int(binary.BigEndian.Uint32(e32))
 
 	decodedN, err := base64.RawURLEncoding.DecodeString(rawN)
-	if err != nil {
+	if err != nil || len(decodedN) == 0 {
 		return nil, errors.New("public key modulus is invalid")
 	}
 
@@ -185,3 +442,46 @@ func getPublicKey(rawE, rawN string) (*rsa.PublicKey, error) {
 		E: e,
 	}, nil
 }
+
+func getECPublicKey(rawCrv, rawX, rawY string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	var ecdhCurve ecdh.Curve
+	switch rawCrv {
+	case "P-256":
+		curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+	case "P-384":
+		curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+	case "P-521":
+		curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+	default:
+		return nil, errors.New("public key curve " + rawCrv + " is not supported")
+	}
+
+	decodedX, err := base64.RawURLEncoding.DecodeString(rawX)
+	if err != nil || len(decodedX) == 0 {
+		return nil, errors.New("public key x coordinate is invalid")
+	}
+	decodedY, err := base64.RawURLEncoding.DecodeString(rawY)
+	if err != nil || len(decodedY) == 0 {
+		return nil, errors.New("public key y coordinate is invalid")
+	}
+
+	// ecdh will refuse a point that is not on the curve
+	size := (curve.Params().BitSize + 7) / 8
+	if len(decodedX) > size || len(decodedY) > size {
+		return nil, errors.New("public key coordinates are too large for the curve")
+	}
+	point := make([]byte, 1+2*size)
+	point[0] = 4 // uncompressed form
+	copy(point[1+size-len(decodedX):1+size], decodedX)
+	copy(point[1+2*size-len(decodedY):], decodedY)
+	if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+		return nil, errors.New("public key is not on the curve " + rawCrv)
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(decodedX),
+		Y:     new(big.Int).SetBytes(decodedY),
+	}, nil
+}
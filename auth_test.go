@@ -1,11 +1,22 @@
 package cognito
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/dgrijalva/jwt-go"
 )
 
 // later might be better to move that in testdata folder
@@ -19,7 +30,7 @@ const (
 )
 
 // not a real aws key, but just the public used for the test token
-var testValidawsKeys = map[string]*awsWellKnowKey{
+var testValidawsKeys = mustParseKeys(map[string]*awsWellKnowKey{
 	"KIDxa": &awsWellKnowKey{
 		Alg: "RS256",
 		E:   "AQAB",
@@ -28,6 +39,20 @@ var testValidawsKeys = map[string]*awsWellKnowKey{
 		N:   "AJ88orNWY3zQdGwYChTEr75E7cJbwbGiau0ucAPpM3lTlaVVsJFnVYWuLN_FzP6Wv8q-O2r-_s91U5rw0cgB3Gk_dsIURBaS7_XI-ZU3iUom8q_zK5v2LYwmVVoGjmCIcK18Ci6j6_9dYp1rAJHyMrbx1k8WWBHFy4AFxblLmkt7hfYBIjUMMxk1Nb9BapKkwa-AfJ1txwjeO11LtLfGNHvpX-LODsUGsFg-_Sff-Xd0ctL21dwJtRbRiYibzsEbCH1QoQ6WErU3B0wjKrb1m1ei9dQVpKcxl0luB7-N6mvhkmDg9kFOvDG-faEpNjgfgbTi6SaH5mxhBoL5sMgiPTM",
 		Use: "sig",
 	},
+})
+
+// mustParseKeys load the public keys the same way getAwsKey would do
+func mustParseKeys(keys map[string]*awsWellKnowKey) map[string]*awsWellKnowKey {
+	for _, key := range keys {
+		if err := key.parsePublicKey(); err != nil {
+			panic(err)
+		}
+	}
+	return keys
+}
+
+func mustParseKey(key *awsWellKnowKey) *awsWellKnowKey {
+	return mustParseKeys(map[string]*awsWellKnowKey{key.Kid: key})[key.Kid]
 }
 
 func TestAuth_getAwsKey(t *testing.T) {
@@ -82,14 +107,14 @@ func TestAuth_getAwsKey(t *testing.T) {
 				userPoolID: "us-east-1_MQnn7mzKZ",
 			},
 			args{k: "03oly5+cLzmYbfTUlI1Zebd5/rMaQNND5kgFXhP8s/0="},
-			&awsWellKnowKey{
+			mustParseKey(&awsWellKnowKey{
 				Alg: "RS256",
 				E:   "AQAB",
 				Kid: "03oly5+cLzmYbfTUlI1Zebd5/rMaQNND5kgFXhP8s/0=",
 				Kty: "RSA",
 				N:   "slmdpk2Y6267KcI49coGvIPU3DKwOhUtKMIAfOgm8J5_Z56Sk85pmeGhqEtFNMbmuP89W-Ea80VAgBKNtzMWYV-YJOR94wpMH37NvrgedLa_zdjg2oGfAuJjSZChrjbvHcU8STm0SxzrdvlX55al9FCzUSa6jq30tUbKeCJGludsjJImLUOv4qwYaYdWbmYUQjWrkisxg15ADpJglsRdMF4mB48OlsI44zP7gRIVrjZwSWvQ11zby1bRid3POqPxejkYz1Nd98eEumDdYUrxRXuKaNzyJ42roQMjfpbY46n-XDdmM53nxJNFwcRKbsLMSmvPFkezxXNw201RB1CKzQ",
 				Use: "sig",
-			},
+			}),
 			false,
 		},
 		{
@@ -100,14 +125,14 @@ func TestAuth_getAwsKey(t *testing.T) {
 				userPoolID: "us-east-1_MQnn7mzKZ",
 			},
 			args{k: "XN9pj9+EdO59lukoJBhxDbWkFS6x3xaM2OG21lbt2bQ="},
-			&awsWellKnowKey{
+			mustParseKey(&awsWellKnowKey{
 				Alg: "RS256",
 				E:   "AQAB",
 				Kid: "XN9pj9+EdO59lukoJBhxDbWkFS6x3xaM2OG21lbt2bQ=",
 				Kty: "RSA",
 				N:   "pAb8ODqcckFiC5gWOEFKAtv7qGl4tpbutUTRBIsVLfcO--Mu3V7qS3K9QFDM6P-6DmqavL9-q1uavcxiwANXkCUkA_9tREFYmUNGBYd3aAxjHtOWpE82agA10BpO-bL02ES2G66LBlYBsOyv6PpZTW7Dqd17pkn42le7_IOFneaoXhKsm-XkwNfk9PLgG814k5FjrrYVw1_fiPCiag4blQHSGHaDggH9NGRzobS7MZMYQ05QU6gsIDCPcYm0u-hOm8gKshsWqWcTELHfd8_MA5M6hPYhqa-HxAvDV-BCCoRCvatLWhsTEUjI_-2I9zgg9x9uAN1A6r8E1wviVIyDHQ",
 				Use: "sig",
-			},
+			}),
 			false,
 		},
 		{
@@ -140,7 +165,7 @@ func TestAuth_getAwsKey(t *testing.T) {
 				region:     tt.fields.region,
 				userPoolID: tt.fields.userPoolID,
 			}
-			got, err := aws.getAwsKey(tt.args.k)
+			got, err := aws.getAwsKey(context.Background(), tt.args.k)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Auth.getAwsKey() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -211,6 +236,67 @@ func Test_getPublicKey(t *testing.T) {
 	}
 }
 
+func Test_awsWellKnowKey_parsePublicKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     *awsWellKnowKey
+		wantErr bool
+	}{
+		{
+			"should parse a valid key",
+			&awsWellKnowKey{Kid: "KIDxa", Kty: "RSA", E: "AQAB", N: testValidawsKeys["KIDxa"].N},
+			false,
+		},
+		{
+			"should reject a key with an invalid modulus",
+			&awsWellKnowKey{Kid: "KIDxa", Kty: "RSA", E: "AQAB", N: "!@#!@#!$!@"},
+			true,
+		},
+		{
+			"should reject a key without modulus",
+			&awsWellKnowKey{Kid: "KIDxa", Kty: "RSA", E: "AQAB"},
+			true,
+		},
+		{
+			"should parse a valid EC key",
+			testJWK("KIDec", "ES256", &testECKey.PublicKey),
+			false,
+		},
+		{
+			"should reject an EC key that is not on the curve",
+			&awsWellKnowKey{Kid: "KIDec", Kty: "EC", Crv: "P-256", X: testJWK("KIDec", "ES256", &testECKey.PublicKey).X, Y: "AQAB"},
+			true,
+		},
+		{
+			"should reject an EC key with an unknown curve",
+			&awsWellKnowKey{Kid: "KIDec", Kty: "EC", Crv: "P-224", X: "AQAB", Y: "AQAB"},
+			true,
+		},
+		{
+			"should reject an unknown kty",
+			&awsWellKnowKey{Kid: "KIDoct", Kty: "oct", N: "AQAB"},
+			true,
+		},
+		{
+			"should reject a key without exponent",
+			&awsWellKnowKey{Kid: "KIDxa", Kty: "RSA", N: testValidawsKeys["KIDxa"].N},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.key.parsePublicKey()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("awsWellKnowKey.parsePublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if (tt.key.publicKey == nil) != tt.wantErr {
+				t.Errorf("awsWellKnowKey.parsePublicKey() publicKey = %v", tt.key.publicKey)
+			}
+		})
+	}
+}
+
 func TestAuth_ValidateToken(t *testing.T) {
 	type fields struct {
 		awsKeys    map[string]*awsWellKnowKey
@@ -281,6 +367,106 @@ func TestAuth_ValidateToken(t *testing.T) {
 	}
 }
 
+var (
+	testRSAKey, _   = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _    = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testEC384Key, _ = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	testEC521Key, _ = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+)
+
+// testJWK build the jwk as an issuer would publish it
+func testJWK(kid, alg string, publicKey crypto.PublicKey) *awsWellKnowKey {
+	key := &awsWellKnowKey{Alg: alg, Kid: kid, Use: "sig"}
+	switch k := publicKey.(type) {
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = base64.RawURLEncoding.EncodeToString(k.N.Bytes())
+		key.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		key.Kty = "EC"
+		key.Crv = k.Curve.Params().Name
+		key.X = base64.RawURLEncoding.EncodeToString(k.X.FillBytes(make([]byte, size)))
+		key.Y = base64.RawURLEncoding.EncodeToString(k.Y.FillBytes(make([]byte, size)))
+	}
+	return key
+}
+
+// testSign create a token signed with the given method and kid
+func testSign(method jwt.SigningMethod, kid string, privateKey interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	tokenString, err := token.SignedString(privateKey)
+	if err != nil {
+		panic(err)
+	}
+	return tokenString
+}
+
+func TestAuth_ValidateToken_algorithms(t *testing.T) {
+	claims := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": 9.56034296e+09}
+	keys := mustParseKeys(map[string]*awsWellKnowKey{
+		"RS256": testJWK("RS256", "RS256", &testRSAKey.PublicKey),
+		"PS256": testJWK("PS256", "PS256", &testRSAKey.PublicKey),
+		"PS384": testJWK("PS384", "PS384", &testRSAKey.PublicKey),
+		"PS512": testJWK("PS512", "PS512", &testRSAKey.PublicKey),
+		"ES256": testJWK("ES256", "ES256", &testECKey.PublicKey),
+		"ES384": testJWK("ES384", "ES384", &testEC384Key.PublicKey),
+		"ES512": testJWK("ES512", "ES512", &testEC521Key.PublicKey),
+		"EC":    testJWK("EC", "", &testECKey.PublicKey),
+		"RSA":   testJWK("RSA", "", &testRSAKey.PublicKey),
+	})
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"should succeed: RS256", testSign(jwt.SigningMethodRS256, "RS256", testRSAKey, claims), false},
+		{"should succeed: PS256", testSign(jwt.SigningMethodPS256, "PS256", testRSAKey, claims), false},
+		{"should succeed: PS384", testSign(jwt.SigningMethodPS384, "PS384", testRSAKey, claims), false},
+		{"should succeed: PS512", testSign(jwt.SigningMethodPS512, "PS512", testRSAKey, claims), false},
+		{"should succeed: ES256", testSign(jwt.SigningMethodES256, "ES256", testECKey, claims), false},
+		{"should succeed: ES384", testSign(jwt.SigningMethodES384, "ES384", testEC384Key, claims), false},
+		{"should succeed: ES512", testSign(jwt.SigningMethodES512, "ES512", testEC521Key, claims), false},
+		{"should succeed: EC key without alg", testSign(jwt.SigningMethodES256, "EC", testECKey, claims), false},
+		{"should succeed: RSA key without alg", testSign(jwt.SigningMethodPS256, "RSA", testRSAKey, claims), false},
+		{"should return error: alg does not match the key alg", testSign(jwt.SigningMethodPS256, "RS256", testRSAKey, claims), true},
+		{"should return error: RSA method with an EC key", testSign(jwt.SigningMethodRS256, "EC", testRSAKey, claims), true},
+		{"should return error: EC method with an RSA key", testSign(jwt.SigningMethodES256, "RSA", testECKey, claims), true},
+		{"should return error: EC method with the wrong curve", testSign(jwt.SigningMethodES384, "EC", testEC384Key, claims), true},
+		{"should return error: HMAC method", testSign(jwt.SigningMethodHS256, "RSA", []byte("secret"), claims), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aws := &Auth{awsKeys: keys}
+			_, err := aws.ValidateToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Auth.ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuth_ValidateToken_option(t *testing.T) {
+	claims := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": 9.56034296e+09}
+	aws := &Auth{awsKeys: mustParseKeys(map[string]*awsWellKnowKey{
+		"ES256": testJWK("ES256", "ES256", &testECKey.PublicKey),
+	})}
+	var got TokenInfo
+	_, err := aws.ValidateToken(testSign(jwt.SigningMethodES256, "ES256", testECKey, claims),
+		func(token TokenInfo, claims map[string]interface{}) error {
+			got = token
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Auth.ValidateToken() error = %v", err)
+	}
+	want := TokenInfo{Alg: "ES256", Header: map[string]interface{}{"alg": "ES256", "kid": "ES256", "typ": "JWT"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidateOption token = %v, want %v", got, want)
+	}
+}
+
 func TestAuth_ValidateAccessToken(t *testing.T) {
 	iat, _ := time.Parse(time.RFC3339, "1987-10-04T09:49:19.000Z")
 	exp, _ := time.Parse(time.RFC3339, "2272-12-15T02:49:20.000Z")
@@ -395,3 +581,371 @@ func TestAuth_ValidateIDToken(t *testing.T) {
 		})
 	}
 }
+
+func Benchmark_getPublicKey(b *testing.B) {
+	key := testValidawsKeys["KIDxa"]
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := getPublicKey(key.E, key.N); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAuth_ValidateToken(b *testing.B) {
+	aws := &Auth{awsKeys: testValidawsKeys}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := aws.ValidateToken(validTestIDToken); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAuth_Keys(t *testing.T) {
+	encryption := testJWK("kid-enc", "RS256", &testRSAKey.PublicKey)
+	encryption.Use = "enc"
+	jwks, _ := json.Marshal(awsWellKnowKeys{Keys: []*awsWellKnowKey{
+		testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey),
+		testJWK("kid-ec", "ES256", &testECKey.PublicKey),
+		{Kid: "kid-malformed", Kty: "RSA", Alg: "RS256", E: "AQAB"},
+		encryption,
+	}})
+	refreshed := false
+	aws := New("", "", WithJWKS(jwks), WithHooks(Hooks{
+		OnKeysRefreshed: func(old, new []Key) { refreshed = true },
+	}))
+	got, err := aws.Keys()
+	if err != nil {
+		t.Fatalf("Auth.Keys() error = %v", err)
+	}
+	if len(aws.awsKeys) != 0 || refreshed {
+		t.Errorf("Auth.Keys() should not change the cached keys, got %v keys", len(aws.awsKeys))
+	}
+	want := []Key{
+		{Kid: "kid-ec", Alg: "ES256", Kty: "EC", Use: "sig", Crv: "P-256", Bits: 256},
+		{Kid: "kid-enc", Alg: "RS256", Kty: "RSA", Use: "enc", Bits: 2048},
+		{Kid: "kid-malformed", Alg: "RS256", Kty: "RSA"},
+		{Kid: "kid-rsa", Alg: "RS256", Kty: "RSA", Use: "sig", Bits: 2048},
+	}
+	wantErr := []string{"", `kid: kid-enc is rejected: use "enc" is not sig`, "kid: kid-malformed is malformed", ""}
+	if len(got) != len(want) {
+		t.Fatalf("Auth.Keys() = %v, want %v", got, want)
+	}
+	for i := range got {
+		gotErr := ""
+		if got[i].Err != nil {
+			gotErr = got[i].Err.Error()
+		}
+		if !strings.HasPrefix(gotErr, wantErr[i]) || (gotErr == "") != (wantErr[i] == "") {
+			t.Errorf("Auth.Keys()[%d].Err = %v, want %v", i, got[i].Err, wantErr[i])
+		}
+		got[i].Err = nil
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Auth.Keys() = %v, want %v", got, want)
+	}
+}
+
+func Test_tokenHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		alg     string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		// see https://openid.net/specs/openid-connect-core-1_0.html#code-id_tokenExample
+		{"should hash with SHA-256", "RS256", "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y", "77QmUPtjPfzWtF2AnpK9RQ", false},
+		{"should hash with SHA-384", "ES384", "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y", "jtAeDp945y1dDqU3nkIVGNZP1HjH_MFs", false},
+		{"should fail without hash", "none", "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokenHash(tt.alg, tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("tokenHash() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("tokenHash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuth_ValidateIDTokenWithNonce(t *testing.T) {
+	accessToken := "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"
+	keys := mustParseKeys(map[string]*awsWellKnowKey{
+		"RS256": testJWK("RS256", "RS256", &testRSAKey.PublicKey),
+		"ES384": testJWK("ES384", "ES384", &testEC384Key.PublicKey),
+	})
+	idToken := func(method jwt.SigningMethod, privateKey interface{}, nonce, atHash string) string {
+		claims := jwt.MapClaims{"token_use": "id", "exp": 9.56034296e+09, "nonce": nonce, "at_hash": atHash}
+		return testSign(method, method.Alg(), privateKey, claims)
+	}
+	type args struct {
+		IDToken string
+		nonce   string
+		opts    []ValidateOption
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr error
+	}{
+		{
+			"should succeed: valid nonce",
+			args{idToken(jwt.SigningMethodRS256, testRSAKey, "n-0S6_WzA2Mj", ""), "n-0S6_WzA2Mj", nil},
+			nil,
+		},
+		{
+			"should succeed: valid nonce and at_hash",
+			args{idToken(jwt.SigningMethodRS256, testRSAKey, "n-0S6_WzA2Mj", "77QmUPtjPfzWtF2AnpK9RQ"), "n-0S6_WzA2Mj", []ValidateOption{WithAccessTokenHash(accessToken)}},
+			nil,
+		},
+		{
+			"should succeed: at_hash with the alg of the token",
+			args{idToken(jwt.SigningMethodES384, testEC384Key, "n-0S6_WzA2Mj", "jtAeDp945y1dDqU3nkIVGNZP1HjH_MFs"), "n-0S6_WzA2Mj", []ValidateOption{WithAccessTokenHash(accessToken)}},
+			nil,
+		},
+		{
+			"should return error: invalid nonce",
+			args{idToken(jwt.SigningMethodRS256, testRSAKey, "n-0S6_WzA2Mj", ""), "other", nil},
+			ErrInvalidNonce,
+		},
+		{
+			"should return error: empty nonce",
+			args{idToken(jwt.SigningMethodRS256, testRSAKey, "", ""), "", nil},
+			ErrInvalidNonce,
+		},
+		{
+			"should return error: invalid at_hash",
+			args{idToken(jwt.SigningMethodRS256, testRSAKey, "n-0S6_WzA2Mj", "77QmUPtjPfzWtF2AnpK9RQ"), "n-0S6_WzA2Mj", []ValidateOption{WithAccessTokenHash("other")}},
+			ErrInvalidAtHash,
+		},
+		{
+			"should return error: at_hash of an other alg",
+			args{idToken(jwt.SigningMethodES384, testEC384Key, "n-0S6_WzA2Mj", "77QmUPtjPfzWtF2AnpK9RQ"), "n-0S6_WzA2Mj", []ValidateOption{WithAccessTokenHash(accessToken)}},
+			ErrInvalidAtHash,
+		},
+		{
+			"should return error: missing at_hash",
+			args{idToken(jwt.SigningMethodRS256, testRSAKey, "n-0S6_WzA2Mj", ""), "n-0S6_WzA2Mj", []ValidateOption{WithAccessTokenHash(accessToken)}},
+			ErrInvalidAtHash,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aws := &Auth{awsKeys: keys}
+			got, err := aws.ValidateIDTokenWithNonce(tt.args.IDToken, tt.args.nonce, tt.args.opts...)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("Auth.ValidateIDTokenWithNonce() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Nonce != tt.args.nonce {
+				t.Errorf("Auth.ValidateIDTokenWithNonce() nonce = %v, want %v", got.Nonce, tt.args.nonce)
+			}
+		})
+	}
+	t.Run("should not write in the options of the caller", func(t *testing.T) {
+		aws := &Auth{awsKeys: keys}
+		opts := make([]ValidateOption, 0, 1)
+		if _, err := aws.ValidateIDTokenWithNonce(idToken(jwt.SigningMethodRS256, testRSAKey, "n-0S6_WzA2Mj", ""), "n-0S6_WzA2Mj", opts...); err != nil {
+			t.Fatalf("Auth.ValidateIDTokenWithNonce() error = %v", err)
+		}
+		if opts[:1][0] != nil {
+			t.Errorf("Auth.ValidateIDTokenWithNonce() appended the nonce option to the options of the caller")
+		}
+	})
+}
+
+func TestAuth_ValidateIDToken_standardClaims(t *testing.T) {
+	keys := mustParseKeys(map[string]*awsWellKnowKey{"RS256": testJWK("RS256", "RS256", &testRSAKey.PublicKey)})
+	iat, _ := time.Parse(time.RFC3339, "2019-06-12T11:35:59.000Z")
+	exp, _ := time.Parse(time.RFC3339, "2272-12-15T02:49:20.000Z")
+	updatedAt, _ := time.Parse(time.RFC3339, "2019-05-01T10:00:00.000Z")
+	claims := jwt.MapClaims{
+		"sub":                   "3a9f2f3a-e659-41da-b116-0902d1f7d4ea",
+		"token_use":             "id",
+		"auth_time":             1.560339359e+09,
+		"exp":                   9.56034296e+09,
+		"iat":                   1.560339359e+09,
+		"jti":                   "4c6ad0a5-5d4d-4e4e-a4b0-1e0b1a5d3a11",
+		"origin_jti":            "8e5b3c2a-1f0e-4d6b-9c7a-2b1d0e9f8a7c",
+		"name":                  "Marc Ttt",
+		"given_name":            "Marc",
+		"family_name":           "Ttt",
+		"middle_name":           "J",
+		"nickname":              "marco",
+		"profile":               "https://example.com/marc",
+		"picture":               "https://example.com/marc.png",
+		"website":               "https://marc.example.com",
+		"gender":                "male",
+		"birthdate":             "1987-10-04",
+		"zoneinfo":              "Europe/Paris",
+		"locale":                "fr-FR",
+		"phone_number":          "+33600000000",
+		"phone_number_verified": true,
+		"address": map[string]interface{}{
+			"formatted":      "1 rue de Rivoli\n75001 Paris\nFrance",
+			"street_address": "1 rue de Rivoli",
+			"locality":       "Paris",
+			"region":         "Ile-de-France",
+			"postal_code":    "75001",
+			"country":        "FR",
+		},
+		"updated_at": 1.5567048e+09,
+	}
+	want := &IDTokenPayload{
+		Sub:                 "3a9f2f3a-e659-41da-b116-0902d1f7d4ea",
+		TokenUse:            "id",
+		Jti:                 "4c6ad0a5-5d4d-4e4e-a4b0-1e0b1a5d3a11",
+		OriginJti:           "8e5b3c2a-1f0e-4d6b-9c7a-2b1d0e9f8a7c",
+		AuthTime:            iat,
+		Exp:                 exp,
+		Iat:                 iat,
+		Name:                "Marc Ttt",
+		GivenName:           "Marc",
+		FamilyName:          "Ttt",
+		MiddleName:          "J",
+		Nickname:            "marco",
+		Profile:             "https://example.com/marc",
+		Picture:             "https://example.com/marc.png",
+		Website:             "https://marc.example.com",
+		Gender:              "male",
+		Birthdate:           "1987-10-04",
+		Zoneinfo:            "Europe/Paris",
+		Locale:              "fr-FR",
+		PhoneNumber:         "+33600000000",
+		PhoneNumberVerified: true,
+		Address: &Address{
+			Formatted:     "1 rue de Rivoli\n75001 Paris\nFrance",
+			StreetAddress: "1 rue de Rivoli",
+			Locality:      "Paris",
+			Region:        "Ile-de-France",
+			PostalCode:    "75001",
+			Country:       "FR",
+		},
+		UpdatedAt: updatedAt,
+	}
+	aws := &Auth{awsKeys: keys}
+	got, err := aws.ValidateIDToken(testSign(jwt.SigningMethodRS256, "RS256", testRSAKey, claims))
+	if err != nil {
+		t.Fatalf("Auth.ValidateIDToken() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Auth.ValidateIDToken() = \n%+v, \nwant \n%+v", got, want)
+	}
+}
+
+func TestAuth_checkKey(t *testing.T) {
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withExponent := func(e string) *awsWellKnowKey {
+		key := testJWK("kid", "RS256", &testRSAKey.PublicKey)
+		key.E = e
+		return key
+	}
+	withUse := func(use string) *awsWellKnowKey {
+		key := testJWK("kid", "RS256", &testRSAKey.PublicKey)
+		key.Use = use
+		return key
+	}
+	tests := []struct {
+		name    string
+		aws     *Auth
+		key     *awsWellKnowKey
+		wantErr string
+	}{
+		{"should accept an rsa key", &Auth{}, testJWK("kid", "RS256", &testRSAKey.PublicKey), ""},
+		{"should accept an rsa key without alg", &Auth{}, testJWK("kid", "", &testRSAKey.PublicKey), ""},
+		{"should accept an rsa-pss key", &Auth{}, testJWK("kid", "PS384", &testRSAKey.PublicKey), ""},
+		{"should accept an ec key", &Auth{}, testJWK("kid", "ES384", &testEC384Key.PublicKey), ""},
+		{"should accept the exponent 3", &Auth{}, withExponent("Aw"), ""},
+		{"should reject an encryption key", &Auth{}, withUse("enc"), `use "enc" is not sig`},
+		{"should reject a key without use", &Auth{}, withUse(""), `use "" is not sig`},
+		{"should reject an rsa key for an ec alg", &Auth{}, testJWK("kid", "ES256", &testRSAKey.PublicKey), "kty RSA can not be used with alg ES256"},
+		{"should reject an ec key for an rsa alg", &Auth{}, testJWK("kid", "RS256", &testECKey.PublicKey), "kty EC can not be used with alg RS256"},
+		{"should reject an ec key of an other curve", &Auth{}, testJWK("kid", "ES256", &testEC384Key.PublicKey), "kty EC can not be used with alg ES256"},
+		{"should reject an hmac alg", &Auth{}, testJWK("kid", "HS256", &testRSAKey.PublicKey), "can not be used with alg HS256"},
+		{"should reject an unknown alg", &Auth{}, testJWK("kid", "none?", &testRSAKey.PublicKey), "alg none? is not supported"},
+		{"should reject a short modulus by default", &Auth{}, testJWK("kid", "RS256", &rsa1024.PublicKey), "rsa modulus of 1024 bits, the minimum is 2048"},
+		{"should accept a short modulus above the minimum", New("", "", WithMinRSAKeySize(1024)), testJWK("kid", "RS256", &rsa1024.PublicKey), ""},
+		{"should reject a modulus below the minimum", New("", "", WithMinRSAKeySize(3072)), testJWK("kid", "RS256", &testRSAKey.PublicKey), "rsa modulus of 2048 bits, the minimum is 3072"},
+		{"should reject the exponent 1", &Auth{}, withExponent("AQ"), "rsa exponent 1 is invalid"},
+		{"should reject an even exponent", &Auth{}, withExponent("AQAA"), "rsa exponent 65536 is invalid"},
+		{"should reject a too large exponent", &Auth{}, withExponent("_____w"), "rsa exponent 4294967295 is invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.aws.checkKey(mustParseKey(tt.key))
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Auth.checkKey() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Auth.checkKey() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuth_loadKeys_noUsableKey(t *testing.T) {
+	srv, setKeys := newTestRotatingJWKS(t)
+	aws := New("", "", WithJWKSURL(srv.URL))
+	claims := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": 9.56034296e+09}
+	rsaToken := testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims)
+	encryption := testJWK("kid-enc", "RS256", &testRSAKey.PublicKey)
+	encryption.Use = "enc"
+
+	setKeys(testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey))
+	if _, err := aws.ValidateToken(rsaToken); err != nil {
+		t.Fatalf("Auth.ValidateToken() error = %v", err)
+	}
+	for name, keys := range map[string][]*awsWellKnowKey{
+		"empty jwks":    nil,
+		"rejected keys": {encryption},
+	} {
+		setKeys(keys...)
+		var fe *fetchError
+		if err := aws.loadKeys(context.Background()); !errors.As(err, &fe) || !errors.Is(err, errNoUsableKey) {
+			t.Errorf("%s: Auth.loadKeys() error = %v, want errNoUsableKey", name, err)
+		}
+		if _, err := aws.ValidateToken(rsaToken); err != nil {
+			t.Errorf("%s: Auth.ValidateToken() error = %v, the cached keys should be kept", name, err)
+		}
+	}
+
+	setKeys(testJWK("kid-ec", "ES256", &testECKey.PublicKey))
+	if err := aws.loadKeys(context.Background()); err != nil {
+		t.Fatalf("Auth.loadKeys() error = %v", err)
+	}
+	if _, err := aws.ValidateToken(rsaToken); !errors.Is(err, ErrKidNotFound) {
+		t.Errorf("Auth.ValidateToken() error = %v, want ErrKidNotFound once the jwks has other keys", err)
+	}
+}
+
+func TestAuth_loadKeys_rejected(t *testing.T) {
+	encryption := testJWK("kid-enc", "RS256", &testRSAKey.PublicKey)
+	encryption.Use = "enc"
+	jwks, _ := json.Marshal(awsWellKnowKeys{Keys: []*awsWellKnowKey{
+		testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey),
+		testJWK("kid-mismatch", "ES256", &testRSAKey.PublicKey),
+		encryption,
+	}})
+	aws := New("", "", WithJWKS(jwks))
+	claims := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": 9.56034296e+09}
+	if _, err := aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims)); err != nil {
+		t.Errorf("Auth.ValidateToken() error = %v", err)
+	}
+	if _, err := aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-enc", testRSAKey, claims)); !errors.Is(err, ErrKidNotFound) {
+		t.Errorf("Auth.ValidateToken() error = %v, want ErrKidNotFound", err)
+	}
+	aws.awsKeysLock.RLock()
+	defer aws.awsKeysLock.RUnlock()
+	if got := keyInfos(aws.awsKeys); len(got) != 1 || got[0].Kid != "kid-rsa" {
+		t.Errorf("Auth.awsKeys = %v, want only kid-rsa", got)
+	}
+}
@@ -0,0 +1,65 @@
+package authorizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MethodARN is the parsed methodArn of an authorizer event, for example
+// arn:aws:execute-api:us-east-1:123456789012:a1b2c3d4e5/prod/GET/orders/42
+type MethodARN struct {
+	Partition string
+	Region    string
+	AccountID string
+	APIID     string
+	Stage     string
+	Method    string
+	// Resource is the path without the leading slash, it can be empty
+	Resource string
+}
+
+// ParseMethodARN parse the methodArn of an authorizer event
+func ParseMethodARN(arn string) (MethodARN, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "execute-api" {
+		return MethodARN{}, fmt.Errorf("invalid method arn %q", arn)
+	}
+	path := strings.SplitN(parts[5], "/", 4)
+	if len(path) < 3 || path[0] == "" || path[1] == "" || path[2] == "" {
+		return MethodARN{}, fmt.Errorf("invalid method arn %q", arn)
+	}
+	m := MethodARN{
+		Partition: parts[1],
+		Region:    parts[3],
+		AccountID: parts[4],
+		APIID:     path[0],
+		Stage:     path[1],
+		Method:    path[2],
+	}
+	if len(path) == 4 {
+		m.Resource = path[3]
+	}
+	return m, nil
+}
+
+// String return the arn of the method
+func (m MethodARN) String() string {
+	return m.prefix() + m.Method + "/" + m.Resource
+}
+
+func (m MethodARN) prefix() string {
+	return fmt.Sprintf("arn:%s:execute-api:%s:%s:%s/%s/", m.Partition, m.Region, m.AccountID, m.APIID, m.Stage)
+}
+
+// StageResource allow or deny all the methods of the stage. It is the
+// resource to use when the authorizer result is cached, as API Gateway
+// reuses the policy for the other methods called with the same token
+func StageResource(arn MethodARN) string {
+	return arn.prefix() + "*/*"
+}
+
+// MethodResource allow or deny only the called method, it should only be
+// used when the authorizer result is not cached
+func MethodResource(arn MethodARN) string {
+	return arn.String()
+}
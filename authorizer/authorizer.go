@@ -0,0 +1,147 @@
+// Package authorizer turns a cognito.Auth into an API Gateway lambda
+// authorizer, of TOKEN or REQUEST type.
+//
+//	a := authorizer.New(auth, cognito.WithScopes("orders/read"))
+//	lambda.Start(a.Token)
+//
+// A missing or invalid token is answered with ErrUnauthorized (401), a valid
+// token that does not match the rules with a Deny policy (403) and a valid
+// one with an Allow policy whose context holds the claims.
+// see https://docs.aws.amazon.com/apigateway/latest/developerguide/apigateway-use-lambda-authorizer.html
+package authorizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/dgrijalva/jwt-go"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// ErrUnauthorized is returned for a missing or invalid token, API Gateway
+// answers with a 401 only when the error message is exactly "Unauthorized"
+var ErrUnauthorized = errors.New("Unauthorized")
+
+// Authorizer is the handler of the lambda authorizer
+type Authorizer struct {
+	// Resource is the resource of the policy, StageResource by default
+	Resource func(arn MethodARN) string
+
+	auth *cognito.Auth
+	opts []cognito.ValidateOption
+}
+
+// New create an authorizer validating access tokens with auth. opts are the
+// rules a token needs to be allowed, for example cognito.WithScopes
+func New(auth *cognito.Auth, opts ...cognito.ValidateOption) *Authorizer {
+	return &Authorizer{
+		Resource: StageResource,
+		auth:     auth,
+		opts:     append([]cognito.ValidateOption{accessToken}, opts...),
+	}
+}
+
+// Token is the handler of a TOKEN authorizer, the token source must be the
+// `Authorization` header
+func (a *Authorizer) Token(ctx context.Context, event events.APIGatewayCustomAuthorizerRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	return a.authorize(ctx, event.AuthorizationToken, event.MethodArn)
+}
+
+// Request is the handler of a REQUEST authorizer, the token is read from the
+// `Authorization` header of the request
+func (a *Authorizer) Request(ctx context.Context, event events.APIGatewayCustomAuthorizerRequestTypeRequest) (events.APIGatewayCustomAuthorizerResponse, error) {
+	var authorization string
+	for name, value := range event.Headers {
+		if strings.EqualFold(name, "Authorization") {
+			authorization = value
+			break
+		}
+	}
+	return a.authorize(ctx, authorization, event.MethodArn)
+}
+
+func (a *Authorizer) authorize(ctx context.Context, authorization, methodARN string) (events.APIGatewayCustomAuthorizerResponse, error) {
+	arn, err := ParseMethodARN(methodARN)
+	if err != nil {
+		return events.APIGatewayCustomAuthorizerResponse{}, err
+	}
+	tokenString := bearerToken(authorization)
+	if tokenString == "" {
+		return events.APIGatewayCustomAuthorizerResponse{}, ErrUnauthorized
+	}
+	claims, err := a.auth.ValidateTokenContext(ctx, tokenString, a.opts...)
+	if err != nil {
+		if _, code := cognito.Challenge(err); code == http.StatusForbidden {
+			// the token is valid, decode it again for the principal
+			claims := jwt.MapClaims{}
+			new(jwt.Parser).ParseUnverified(tokenString, claims)
+			return a.policy("Deny", claims, arn, nil), nil
+		}
+		return events.APIGatewayCustomAuthorizerResponse{}, ErrUnauthorized
+	}
+	return a.policy("Allow", claims, arn, Context(claims)), nil
+}
+
+func (a *Authorizer) policy(effect string, claims map[string]interface{}, arn MethodARN, context map[string]interface{}) events.APIGatewayCustomAuthorizerResponse {
+	resource := a.Resource
+	if resource == nil {
+		resource = StageResource
+	}
+	principalID, _ := claims["sub"].(string)
+	return events.APIGatewayCustomAuthorizerResponse{
+		PrincipalID: principalID,
+		PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
+			Version: "2012-10-17",
+			Statement: []events.IAMPolicyStatement{{
+				Action:   []string{"execute-api:Invoke"},
+				Effect:   effect,
+				Resource: []string{resource(arn)},
+			}},
+		},
+		Context: context,
+	}
+}
+
+// Context convert the claims to an authorizer context, that only accepts
+// strings, numbers and booleans: a list of strings (cognito:groups) is comma
+// separated and the other values are dropped
+func Context(claims map[string]interface{}) map[string]interface{} {
+	context := make(map[string]interface{}, len(claims))
+	for name, value := range claims {
+		switch v := value.(type) {
+		case string, float64, bool:
+			context[name] = v
+		case []interface{}:
+			values := make([]string, 0, len(v))
+			for _, item := range v {
+				if s, ok := item.(string); ok {
+					values = append(values, s)
+				}
+			}
+			context[name] = strings.Join(values, ",")
+		}
+	}
+	return context
+}
+
+// accessToken reject the id tokens, only access tokens are authorized
+func accessToken(token cognito.TokenInfo, claims map[string]interface{}) error {
+	if claims["token_use"] != "access" {
+		return fmt.Errorf("token invalid: token_use is %v, want access", claims["token_use"])
+	}
+	return nil
+}
+
+// bearerToken accept the token with or without the Bearer scheme
+func bearerToken(authorization string) string {
+	const prefix = "bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		authorization = authorization[len(prefix):]
+	}
+	return strings.TrimSpace(authorization)
+}
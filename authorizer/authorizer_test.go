@@ -0,0 +1,161 @@
+package authorizer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	cognito "github.com/AyWa/jwt-cognito"
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+// loadEvent read a sample event of testdata with the token in place of {{token}}
+func loadEvent(t *testing.T, name, token string, event interface{}) {
+	raw, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw = []byte(strings.Replace(string(raw), "{{token}}", token, 1))
+	if err := json.Unmarshal(raw, event); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthorizer_Token(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	tests := []struct {
+		name       string
+		opts       []cognito.ValidateOption
+		token      string
+		wantErr    error
+		wantEffect string
+	}{
+		{"should allow a valid token", nil, s.AccessToken(cognitotest.WithGroups("admin", "users")), nil, "Allow"},
+		{"should allow a token with the rules", []cognito.ValidateOption{cognito.WithGroups("admin")}, s.AccessToken(cognitotest.WithGroups("admin")), nil, "Allow"},
+		{"should deny a token outside of the rules", []cognito.ValidateOption{cognito.WithGroups("admin")}, s.AccessToken(cognitotest.WithGroups("users")), nil, "Deny"},
+		{"should deny a token without the scope", []cognito.ValidateOption{cognito.WithScopes("orders/write")}, s.AccessToken(), nil, "Deny"},
+		{"should be unauthorized: expired token", nil, s.AccessToken(cognitotest.WithExpiry(time.Now().Add(-time.Minute))), ErrUnauthorized, ""},
+		{"should be unauthorized: id token", nil, s.IDToken(), ErrUnauthorized, ""},
+		{"should be unauthorized: no token", nil, "", ErrUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := events.APIGatewayCustomAuthorizerRequest{}
+			loadEvent(t, "token.json", tt.token, &event)
+			if tt.token == "" {
+				event.AuthorizationToken = ""
+			}
+			got, err := New(s.Auth(), tt.opts...).Token(context.Background(), event)
+			if err != tt.wantErr {
+				t.Fatalf("Authorizer.Token() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := events.APIGatewayCustomAuthorizerPolicy{
+				Version: "2012-10-17",
+				Statement: []events.IAMPolicyStatement{{
+					Action:   []string{"execute-api:Invoke"},
+					Effect:   tt.wantEffect,
+					Resource: []string{"arn:aws:execute-api:us-east-1:123456789012:a1b2c3d4e5/prod/*/*"},
+				}},
+			}
+			if got.PrincipalID != cognitotest.DefaultSub || !reflect.DeepEqual(got.PolicyDocument, want) {
+				t.Errorf("Authorizer.Token() = %+v, want %+v", got, want)
+			}
+			if tt.wantEffect == "Allow" && got.Context["username"] != cognitotest.DefaultUsername {
+				t.Errorf("Authorizer.Token() context = %v", got.Context)
+			}
+			if tt.wantEffect == "Deny" && got.Context != nil {
+				t.Errorf("Authorizer.Token() context = %v, want nil", got.Context)
+			}
+		})
+	}
+}
+
+func TestAuthorizer_Request(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	a := New(s.Auth())
+	a.Resource = MethodResource
+
+	event := events.APIGatewayCustomAuthorizerRequestTypeRequest{}
+	loadEvent(t, "request.json", s.AccessToken(cognitotest.WithGroups("admin", "users")), &event)
+	got, err := a.Request(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Authorizer.Request() error = %v", err)
+	}
+	statement := got.PolicyDocument.Statement[0]
+	if statement.Effect != "Allow" || statement.Resource[0] != event.MethodArn {
+		t.Errorf("Authorizer.Request() statement = %+v", statement)
+	}
+	if got.Context["cognito:groups"] != "admin,users" || got.Context["client_id"] != s.ClientID {
+		t.Errorf("Authorizer.Request() context = %v", got.Context)
+	}
+
+	delete(event.Headers, "authorization")
+	if _, err := a.Request(context.Background(), event); err != ErrUnauthorized {
+		t.Errorf("Authorizer.Request() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":            "abc",
+		"exp":            float64(1500000000),
+		"email_verified": true,
+		"cognito:groups": []interface{}{"admin", "users"},
+		"address":        map[string]interface{}{"country": "FR"},
+	}
+	want := map[string]interface{}{
+		"sub":            "abc",
+		"exp":            float64(1500000000),
+		"email_verified": true,
+		"cognito:groups": "admin,users",
+	}
+	if got := Context(claims); !reflect.DeepEqual(got, want) {
+		t.Errorf("Context() = %v, want %v", got, want)
+	}
+}
+
+func TestParseMethodARN(t *testing.T) {
+	tests := []struct {
+		arn     string
+		want    MethodARN
+		wantErr bool
+	}{
+		{
+			"arn:aws:execute-api:us-east-1:123456789012:a1b2c3d4e5/prod/GET/orders/42",
+			MethodARN{"aws", "us-east-1", "123456789012", "a1b2c3d4e5", "prod", "GET", "orders/42"},
+			false,
+		},
+		{
+			"arn:aws:execute-api:us-east-1:123456789012:a1b2c3d4e5/prod/GET/",
+			MethodARN{"aws", "us-east-1", "123456789012", "a1b2c3d4e5", "prod", "GET", ""},
+			false,
+		},
+		{"arn:aws:execute-api:us-east-1:123456789012:a1b2c3d4e5/prod", MethodARN{}, true},
+		{"arn:aws:lambda:us-east-1:123456789012:function:auth", MethodARN{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			got, err := ParseMethodARN(tt.arn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMethodARN() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMethodARN() = %+v, want %+v", got, tt.want)
+			}
+			if !tt.wantErr && got.String() != tt.arn {
+				t.Errorf("MethodARN.String() = %v, want %v", got.String(), tt.arn)
+			}
+		})
+	}
+}
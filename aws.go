@@ -1,12 +1,17 @@
 package cognito
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
 )
 
@@ -23,22 +28,147 @@ type awsWellKnowKey struct {
 	Kty string `json:"kty"`
 	N   string `json:"n"`
 	Use string `json:"use"`
+	// EC keys only, cognito itself only use RSA keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+
+	// publicKey is parsed once when the key is loaded, so it does not need
+	// to be decoded again for every token.
+	// It is either a *rsa.PublicKey or a *ecdsa.PublicKey depending on Kty
+	publicKey crypto.PublicKey
+}
+
+// Key describes a public key of the jwks
+type Key struct {
+	Kid string
+	Alg string
+	Kty string
+	Use string
+	// Crv is only set for EC keys
+	Crv string
+	// Bits is the size of the RSA modulus or of the EC curve
+	Bits int
+	// Err is the reason the key is rejected, nil when the key can be used
+	// to validate a token
+	Err error
+}
+
+func (k *awsWellKnowKey) info() Key {
+	info := Key{Kid: k.Kid, Alg: k.Alg, Kty: k.Kty, Use: k.Use, Crv: k.Crv}
+	switch publicKey := k.publicKey.(type) {
+	case *rsa.PublicKey:
+		info.Bits = publicKey.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.Bits = publicKey.Curve.Params().BitSize
+	}
+	return info
+}
+
+// parsePublicKey decodes the raw jwk values into a ready to use public key
+func (k *awsWellKnowKey) parsePublicKey() error {
+	var publicKey crypto.PublicKey
+	var err error
+	switch k.Kty {
+	case "RSA":
+		publicKey, err = getPublicKey(k.E, k.N)
+	case "EC":
+		publicKey, err = getECPublicKey(k.Crv, k.X, k.Y)
+	default:
+		err = errors.New("kty " + k.Kty + " is not supported")
+	}
+	if err != nil {
+		return errors.Wrapf(err, "kid: %s is malformed", k.Kid)
+	}
+	k.publicKey = publicKey
+	return nil
+}
+
+// DefaultMinRSAKeySize is the minimum size in bits of the RSA modulus of a
+// key, see WithMinRSAKeySize
+const DefaultMinRSAKeySize = 2048
+
+// checkKey reject the keys that should not be used to verify a signature:
+// a key not meant for signatures, a key whose type is not the one of its alg,
+// a short RSA modulus or an unusual RSA exponent
+func (aws *Auth) checkKey(k *awsWellKnowKey) error {
+	if k.Use != "sig" {
+		return errors.Errorf("kid: %s is rejected: use %q is not sig", k.Kid, k.Use)
+	}
+	// alg is optional, without it the key type is checked against each token
+	if k.Alg != "" {
+		method := jwt.GetSigningMethod(k.Alg)
+		if method == nil {
+			return errors.Errorf("kid: %s is rejected: alg %s is not supported", k.Kid, k.Alg)
+		}
+		if err := checkSigningKey(method, k.publicKey); err != nil {
+			return errors.Errorf("kid: %s is rejected: kty %s can not be used with alg %s", k.Kid, k.Kty, k.Alg)
+		}
+	}
+	if publicKey, ok := k.publicKey.(*rsa.PublicKey); ok {
+		minSize := aws.minRSAKeySize
+		if minSize <= 0 {
+			minSize = DefaultMinRSAKeySize
+		}
+		if bits := publicKey.N.BitLen(); bits < minSize {
+			return errors.Errorf("kid: %s is rejected: rsa modulus of %d bits, the minimum is %d", k.Kid, bits, minSize)
+		}
+		// the exponent must be odd and at least 3, it is almost always 65537
+		if e := publicKey.E; e < 3 || e%2 == 0 || e > 1<<31-1 {
+			return errors.Errorf("kid: %s is rejected: rsa exponent %d is invalid", k.Kid, e)
+		}
+	}
+	return nil
+}
+
+// keysURL is the cognito jwks url, unless an other one has been configured
+func (aws *Auth) keysURL() string {
+	if aws.jwksURL != "" {
+		return aws.jwksURL
+	}
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json", aws.region, aws.userPoolID)
+}
+
+func (aws *Auth) client() *http.Client {
+	if aws.httpClient != nil {
+		return aws.httpClient
+	}
+	return http.DefaultClient
 }
 
 // later we might abstract fetchKeys with a simple interface in order to test easily without
 // fetch real aws key
-func (aws *Auth) fetchKeys() ([]*awsWellKnowKey, error) {
-	url := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json", aws.region, aws.userPoolID)
-	resp, err := http.Get(url)
+func (aws *Auth) fetchKeys(ctx context.Context) (keys []*awsWellKnowKey, err error) {
+	ctx, span := aws.tracer().Start(ctx, "cognito.fetchKeys")
+	defer func() {
+		recordError(span, err)
+		span.End()
+	}()
+	if aws.jwks != nil {
+		return parseJWKS(aws.jwks)
+	}
+	req, err := http.NewRequest(http.MethodGet, aws.keysURL(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to create aws jwks request")
+	}
+	resp, err := aws.client().Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to fetch aws jwks")
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fail to fetch aws jwks: unexpected status %d", resp.StatusCode)
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to read aws jwks body")
 	}
+	return parseJWKS(body)
+}
+
+func parseJWKS(body []byte) ([]*awsWellKnowKey, error) {
 	keys := &awsWellKnowKeys{}
-	err = json.Unmarshal(body, keys)
+	err := json.Unmarshal(body, keys)
 	if err != nil {
 		return nil, errors.Wrap(err, "fail to unmarshal aws jwks body")
 	}
@@ -56,9 +186,53 @@ type IDTokenPayload struct {
 	Email         string    `mapstructure:"email"`
 	Username      string    `mapstructure:"cognito:username"`
 	PreferName    string    `mapstructure:"preferred_username"`
+	Nonce         string    `mapstructure:"nonce"`
+	AtHash        string    `mapstructure:"at_hash"`
+	Jti           string    `mapstructure:"jti"`
+	OriginJti     string    `mapstructure:"origin_jti"`
 	AuthTime      time.Time `mapstructure:"auth_time"`
 	Exp           time.Time `mapstructure:"exp"`
 	Iat           time.Time `mapstructure:"iat"`
+
+	// OIDC standard claims, only present when the attribute is set and
+	// readable by the app client
+	// see https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+	Name                string    `mapstructure:"name"`
+	GivenName           string    `mapstructure:"given_name"`
+	FamilyName          string    `mapstructure:"family_name"`
+	MiddleName          string    `mapstructure:"middle_name"`
+	Nickname            string    `mapstructure:"nickname"`
+	Profile             string    `mapstructure:"profile"`
+	Picture             string    `mapstructure:"picture"`
+	Website             string    `mapstructure:"website"`
+	Gender              string    `mapstructure:"gender"`
+	Birthdate           string    `mapstructure:"birthdate"`
+	Zoneinfo            string    `mapstructure:"zoneinfo"`
+	Locale              string    `mapstructure:"locale"`
+	PhoneNumber         string    `mapstructure:"phone_number"`
+	PhoneNumberVerified bool      `mapstructure:"phone_number_verified"`
+	Address             *Address  `mapstructure:"address"`
+	UpdatedAt           time.Time `mapstructure:"updated_at"`
+
+	// Identities is only set for federated users, see IsFederated
+	Identities []Identity `mapstructure:"identities"`
+
+	// Groups, Roles and PreferredRole come from the user pool groups,
+	// see EffectiveRole
+	Groups        []string `mapstructure:"cognito:groups"`
+	Roles         []string `mapstructure:"cognito:roles"`
+	PreferredRole string   `mapstructure:"cognito:preferred_role"`
+}
+
+// Address is the OIDC address claim
+// see https://openid.net/specs/openid-connect-core-1_0.html#AddressClaim
+type Address struct {
+	Formatted     string `mapstructure:"formatted"`
+	StreetAddress string `mapstructure:"street_address"`
+	Locality      string `mapstructure:"locality"`
+	Region        string `mapstructure:"region"`
+	PostalCode    string `mapstructure:"postal_code"`
+	Country       string `mapstructure:"country"`
 }
 
 // AccessTokenPayload structure containing the payload of an access token
@@ -71,6 +245,7 @@ type AccessTokenPayload struct {
 	Iss      string    `mapstructure:"iss"`
 	Jti      string    `mapstructure:"jti"`
 	ClientID string    `mapstructure:"client_id"`
+	Groups   []string  `mapstructure:"cognito:groups"`
 	AuthTime time.Time `mapstructure:"auth_time"`
 	Exp      time.Time `mapstructure:"exp"`
 	Iat      time.Time `mapstructure:"iat"`
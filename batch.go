@@ -0,0 +1,142 @@
+package cognito
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Result is the result of the validation of a token of a batch, Claims is
+// only set when Err is nil
+type Result struct {
+	Claims map[string]interface{}
+	Err    error
+}
+
+// ValidateBatch validate many tokens at once, like ValidateToken does for
+// each of them. The keys are resolved once for the whole batch: the jwks is
+// fetched at most once, even when several tokens have an unknown kid. Then the
+// signatures are verified in parallel, see WithBatchConcurrency.
+// The results are in the order of tokens
+func (aws *Auth) ValidateBatch(tokens []string, opts ...ValidateOption) []Result {
+	return aws.ValidateBatchContext(context.Background(), tokens, opts...)
+}
+
+// ValidateBatchContext is similar as ValidateBatch, see ValidateTokenContext
+func (aws *Auth) ValidateBatchContext(ctx context.Context, tokens []string, opts ...ValidateOption) []Result {
+	ctx, span := aws.tracer().Start(ctx, "cognito.ValidateBatch", trace.WithAttributes(attribute.Int("cognito.batch_size", len(tokens))))
+	defer span.End()
+	results := make([]Result, len(tokens))
+	if len(tokens) == 0 {
+		return results
+	}
+	keys := aws.resolveKeys(ctx, tokens)
+	getKey := func(ctx context.Context, kid string) (*awsWellKnowKey, error) {
+		k, ok := keys[kid]
+		if !ok {
+			return aws.getAwsKey(ctx, kid)
+		}
+		trace.SpanFromContext(ctx).SetAttributes(attrKid.String(kid), attrCacheHit.Bool(k.err == nil))
+		return k.key, k.err
+	}
+
+	workers := aws.batchConcurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(tokens) {
+		workers = len(tokens)
+	}
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(tokens) {
+					return
+				}
+				claims, err := aws.validateWithKeys(ctx, tokens[i], opts, getKey)
+				results[i] = Result{Claims: claims, Err: err}
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+type batchKey struct {
+	key *awsWellKnowKey
+	err error
+}
+
+// resolveKeys find the key of each kid of the tokens. The cached keys are
+// refreshed at most once, for the first unknown kid, unless the keys are
+// fetched by kid
+func (aws *Auth) resolveKeys(ctx context.Context, tokens []string) map[string]batchKey {
+	keys := map[string]batchKey{}
+	refreshed := false
+	var refreshErr error
+	for _, tokenString := range tokens {
+		kid, ok := tokenKid(tokenString)
+		if !ok {
+			continue
+		}
+		if _, ok := keys[kid]; ok {
+			continue
+		}
+		aws.awsKeysLock.RLock()
+		key, ok := aws.awsKeys[kid]
+		aws.awsKeysLock.RUnlock()
+		switch {
+		case ok:
+			keys[kid] = batchKey{key: key}
+		case refreshed && aws.keyURL == nil:
+			// the jwks has already been fetched for this batch
+			if refreshErr != nil {
+				keys[kid] = batchKey{err: refreshErr}
+				continue
+			}
+			if aws.hooks.OnUnknownKid != nil {
+				aws.hooks.OnUnknownKid(kid)
+			}
+			keys[kid] = batchKey{err: fmt.Errorf("%w: %s", ErrKidNotFound, kid)}
+		default:
+			key, err := aws.getAwsKey(ctx, kid)
+			keys[kid] = batchKey{key: key, err: err}
+			refreshed = true
+			if _, ok := err.(*fetchError); ok {
+				refreshErr = err
+			}
+		}
+	}
+	return keys
+}
+
+// tokenKid decode the kid of the token header, the same way jwt.Parse does
+func tokenKid(tokenString string) (string, bool) {
+	i := strings.IndexByte(tokenString, '.')
+	if i < 0 {
+		return "", false
+	}
+	raw, err := jwt.DecodeSegment(tokenString[:i])
+	if err != nil {
+		return "", false
+	}
+	header := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return "", false
+	}
+	kid, ok := header["kid"].(string)
+	return kid, ok
+}
@@ -0,0 +1,128 @@
+package cognito
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestAuth_ValidateBatch(t *testing.T) {
+	srv, fetches := newTestIssuer(t, nil,
+		testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey),
+		testJWK("kid-ec", "ES256", &testECKey.PublicKey),
+	)
+	valid := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": time.Now().Add(time.Hour).Unix()}
+	expired := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": time.Now().Add(-time.Hour).Unix()}
+	noKid := jwt.NewWithClaims(jwt.SigningMethodRS256, valid)
+	noKidToken, _ := noKid.SignedString(testRSAKey)
+	tokens := []string{
+		testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, valid),
+		testSign(jwt.SigningMethodES256, "kid-ec", testECKey, valid),
+		testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, expired),
+		testSign(jwt.SigningMethodRS256, "unknown-1", testRSAKey, valid),
+		testSign(jwt.SigningMethodRS256, "unknown-2", testRSAKey, valid),
+		"xx.yy",
+		noKidToken,
+		testSign(jwt.SigningMethodRS256, "unknown-1", testRSAKey, valid),
+	}
+	wantOutcomes := []string{"valid", "valid", "expired", "unknown_kid", "unknown_kid", "malformed", "no_kid", "unknown_kid"}
+
+	for _, concurrency := range []int{1, 3, 100} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			before := atomic.LoadInt64(fetches)
+			var unknown int64
+			aws := New("", "", WithJWKSURL(srv.URL+"/jwks"), WithBatchConcurrency(concurrency), WithHooks(Hooks{
+				OnUnknownKid: func(kid string) { atomic.AddInt64(&unknown, 1) },
+			}))
+			results := aws.ValidateBatch(tokens)
+			if len(results) != len(tokens) {
+				t.Fatalf("Auth.ValidateBatch() returned %v results, want %v", len(results), len(tokens))
+			}
+			for i, result := range results {
+				if got := outcome(result.Err); got != wantOutcomes[i] {
+					t.Errorf("Auth.ValidateBatch()[%d] outcome = %v (%v), want %v", i, got, result.Err, wantOutcomes[i])
+				}
+				if (result.Claims != nil) != (result.Err == nil) {
+					t.Errorf("Auth.ValidateBatch()[%d] = %+v", i, result)
+				}
+			}
+			if got := atomic.LoadInt64(fetches) - before; got != 1 {
+				t.Errorf("the jwks was fetched %v times, want 1", got)
+			}
+			if unknown != 2 {
+				t.Errorf("OnUnknownKid was called %v times, want 2", unknown)
+			}
+		})
+	}
+
+	t.Run("should return the fetch error for all the tokens", func(t *testing.T) {
+		aws := New("", "", WithJWKSURL(srv.URL+"/not-found"), WithHTTPClient(&http.Client{Transport: failingTransport{}}))
+		for i, result := range aws.ValidateBatch(tokens[:2]) {
+			var fe *fetchError
+			if !errors.As(result.Err, &fe) {
+				t.Errorf("Auth.ValidateBatch()[%d] error = %v, want fetch error", i, result.Err)
+			}
+		}
+	})
+
+	t.Run("should accept an empty batch", func(t *testing.T) {
+		if got := New("", "").ValidateBatch(nil); len(got) != 0 {
+			t.Errorf("Auth.ValidateBatch() = %v", got)
+		}
+	})
+}
+
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("network is down")
+}
+
+// benchmarkTokens return n valid tokens signed with 2 keys
+func benchmarkTokens(b *testing.B, n int) (*Auth, []string) {
+	srv, _ := newTestIssuer(b, nil,
+		testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey),
+		testJWK("kid-ec", "ES256", &testECKey.PublicKey),
+	)
+	claims := jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "exp": time.Now().Add(time.Hour).Unix()}
+	tokens := make([]string, n)
+	for i := range tokens {
+		if i%2 == 0 {
+			tokens[i] = testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims)
+		} else {
+			tokens[i] = testSign(jwt.SigningMethodES256, "kid-ec", testECKey, claims)
+		}
+	}
+	return New("", "", WithJWKSURL(srv.URL+"/jwks")), tokens
+}
+
+func BenchmarkAuth_ValidateBatch(b *testing.B) {
+	aws, tokens := benchmarkTokens(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, result := range aws.ValidateBatch(tokens) {
+			if result.Err != nil {
+				b.Fatal(result.Err)
+			}
+		}
+	}
+}
+
+func BenchmarkAuth_ValidateToken_sequential(b *testing.B) {
+	aws, tokens := benchmarkTokens(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, token := range tokens {
+			if _, err := aws.ValidateToken(token); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Command jwt-cognito decodes and validates cognito tokens locally, so they
+// never need to be pasted into a website.
+//
+// Usage:
+//
+//	jwt-cognito decode [token]
+//	jwt-cognito validate --region us-east-1 --pool us-east-1_XXXXXXX [token]
+//	jwt-cognito validate --jwks-file jwks.json [token]
+//	jwt-cognito keys --region us-east-1 --pool us-east-1_XXXXXXX
+//	jwt-cognito serve-introspection --region us-east-1 --pool us-east-1_XXXXXXX --clients-file clients.txt --tls-cert cert.pem --tls-key key.pem
+//	jwt-cognito serve-authz --region us-east-1 --pool us-east-1_XXXXXXX --group admin
+//
+// When the token is not given as argument, it is read from stdin.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+const usage = `Usage: jwt-cognito <command> [flags] [token]
+
+Commands:
+  decode    print the header and the claims of a token without validating it
+  validate  validate a token and print its claims, or the reason it is invalid
+  keys      list the keys of the user pool jwks, with the reason a key is rejected
+  serve-introspection
+            serve a token introspection endpoint (RFC 7662) at /introspect
+  serve-authz
+            serve an Envoy ext_authz and nginx auth_request authorization service
+
+The token is read from stdin when it is not given as argument.
+Run 'jwt-cognito <command> -h' for the flags of a command.
+`
+
+// timeClaims are printed as RFC3339 instead of unix timestamps
+var timeClaims = []string{"exp", "iat", "nbf", "auth_time", "updated_at"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	var err error
+	switch args[0] {
+	case "decode":
+		err = decode(args[1:], stdin, stdout, stderr)
+	case "validate":
+		err = validate(args[1:], stdin, stdout, stderr)
+	case "keys":
+		err = keys(args[1:], stdout, stderr)
+	case "serve-introspection":
+		err = serveIntrospection(args[1:], stderr)
+	case "serve-authz":
+		err = serveAuthz(args[1:], stderr)
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// authFlags are the flags needed to build a cognito.Auth
+type authFlags struct {
+	region   string
+	pool     string
+	issuer   string
+	jwksFile string
+}
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+func (f *authFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.region, "region", "", "aws region of the user pool")
+	fs.StringVar(&f.pool, "pool", "", "user pool id")
+	fs.StringVar(&f.issuer, "issuer", "", "generic OpenID Connect issuer url, instead of a user pool")
+	fs.StringVar(&f.jwksFile, "jwks-file", "", "read the jwks from a file instead of fetching it")
+}
+
+func (f *authFlags) auth(opts ...cognito.Option) (*cognito.Auth, error) {
+	if f.jwksFile != "" {
+		jwks, err := ioutil.ReadFile(f.jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, cognito.WithJWKS(jwks))
+	}
+	switch {
+	case f.issuer != "":
+		return cognito.NewOIDC(context.Background(), f.issuer, opts...)
+	case f.region != "" && f.pool != "":
+		return cognito.New(f.region, f.pool, opts...), nil
+	case f.jwksFile != "":
+		return cognito.New(f.region, f.pool, opts...), nil
+	}
+	return nil, errors.New("--region and --pool, --issuer or --jwks-file is required")
+}
+
+func decode(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("decode", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	tokenString, err := readToken(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	token, _, err := new(jwt.Parser).ParseUnverified(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return err
+	}
+	return printJSON(stdout, map[string]interface{}{
+		"header": token.Header,
+		"claims": formatClaims(token.Claims.(jwt.MapClaims)),
+	})
+}
+
+func validate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := newFlagSet("validate", stderr)
+	var f authFlags
+	f.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	auth, err := f.auth()
+	if err != nil {
+		return err
+	}
+	tokenString, err := readToken(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	claims, err := auth.ValidateToken(tokenString)
+	if err != nil {
+		return fmt.Errorf("token is not valid: %v", err)
+	}
+	return printJSON(stdout, formatClaims(claims))
+}
+
+func keys(args []string, stdout, stderr io.Writer) error {
+	fs := newFlagSet("keys", stderr)
+	var f authFlags
+	f.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	auth, err := f.auth()
+	if err != nil {
+		return err
+	}
+	keys, err := auth.Keys()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tKTY\tALG\tUSE\tBITS\tSTATUS")
+	for _, key := range keys {
+		status := "ok"
+		if key.Err != nil {
+			status = "rejected: " + key.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", key.Kid, key.Kty, key.Alg, key.Use, key.Bits, status)
+	}
+	return w.Flush()
+}
+
+func readToken(args []string, stdin io.Reader) (string, error) {
+	if len(args) > 1 {
+		return "", errors.New("only one token can be given")
+	}
+	if len(args) == 1 && args[0] != "-" {
+		return strings.TrimSpace(args[0]), nil
+	}
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	token := strings.TrimSpace(line)
+	if token == "" {
+		return "", errors.New("no token given")
+	}
+	return token, nil
+}
+
+// formatClaims replace the timestamps by RFC3339 dates
+func formatClaims(claims map[string]interface{}) map[string]interface{} {
+	formatted := make(map[string]interface{}, len(claims))
+	for k, v := range claims {
+		formatted[k] = v
+	}
+	for _, name := range timeClaims {
+		if ts, ok := formatted[name].(float64); ok {
+			formatted[name] = time.Unix(int64(ts), 0).UTC().Format(time.RFC3339)
+		}
+	}
+	return formatted
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
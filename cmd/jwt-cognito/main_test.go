@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func Test_run(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(jwksFile, s.JWKS(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	encryptionFile := filepath.Join(t.TempDir(), "jwks-enc.json")
+	encryption := bytes.Replace(s.JWKS(), []byte(`"use":"sig"`), []byte(`"use":"enc"`), 1)
+	if err := ioutil.WriteFile(encryptionFile, encryption, 0600); err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Date(2272, 12, 15, 2, 49, 20, 0, time.UTC)
+	validToken := s.IDToken(cognitotest.WithExpiry(exp))
+	expiredToken := s.IDToken(cognitotest.WithExpiry(time.Now().Add(-time.Hour)))
+	tests := []struct {
+		name       string
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			"decode should print times as RFC3339",
+			[]string{"decode", validToken},
+			"",
+			0,
+			`"exp": "2272-12-15T02:49:20Z"`,
+			"",
+		},
+		{
+			"decode should read the token from stdin",
+			[]string{"decode"},
+			validToken + "\n",
+			0,
+			`"kid": "` + s.Kid + `"`,
+			"",
+		},
+		{
+			"decode should fail on a malformed token",
+			[]string{"decode", "xx.yy"},
+			"",
+			1,
+			"",
+			"token contains an invalid number of segments",
+		},
+		{
+			"validate should succeed offline",
+			[]string{"validate", "--jwks-file", jwksFile, validToken},
+			"",
+			0,
+			`"token_use": "id"`,
+			"",
+		},
+		{
+			"validate should accept the pool flags",
+			[]string{"validate", "--region", s.Region, "--pool", s.UserPoolID, "--jwks-file", jwksFile, validToken},
+			"",
+			0,
+			`"cognito:username": "test-user"`,
+			"",
+		},
+		{
+			"validate should not fetch the issuer discovery with a jwks file",
+			[]string{"validate", "--issuer", "http://127.0.0.1:0/realms/main", "--jwks-file", jwksFile,
+				s.IDToken(cognitotest.WithExpiry(exp), cognitotest.WithClaim("iss", "http://127.0.0.1:0/realms/main"))},
+			"",
+			0,
+			`"iss": "http://127.0.0.1:0/realms/main"`,
+			"",
+		},
+		{
+			"validate should print the failure reason",
+			[]string{"validate", "--jwks-file", jwksFile, expiredToken},
+			"",
+			1,
+			"",
+			"token is not valid: Token is expired",
+		},
+		{
+			"validate should require the pool",
+			[]string{"validate", validToken},
+			"",
+			1,
+			"",
+			"--region and --pool, --issuer or --jwks-file is required",
+		},
+		{
+			"keys should list the jwks",
+			[]string{"keys", "--jwks-file", jwksFile},
+			"",
+			0,
+			s.Kid + "  RSA  RS256  sig  2048  ok",
+			"",
+		},
+		{
+			"keys should list the rejected keys with the reason",
+			[]string{"keys", "--jwks-file", encryptionFile},
+			"",
+			0,
+			s.Kid + `  RSA  RS256  enc  2048  rejected: kid: ` + s.Kid + ` is rejected: use "enc" is not sig`,
+			"",
+		},
+		{
+			"serve-introspection should require the clients file",
+			[]string{"serve-introspection", "--jwks-file", jwksFile},
+			"",
+			1,
+			"",
+			"--clients-file is required",
+		},
+		{
+			"serve-introspection should require the tls key with the certificate",
+			[]string{"serve-introspection", "--jwks-file", jwksFile, "--clients-file", "clients.txt", "--tls-cert", "cert.pem"},
+			"",
+			1,
+			"",
+			"--tls-cert and --tls-key must be given together",
+		},
+		{
+			"serve-authz should require the pool",
+			[]string{"serve-authz", "--group", "admin"},
+			"",
+			1,
+			"",
+			"--region and --pool, --issuer or --jwks-file is required",
+		},
+		{
+			"should fail on unknown command",
+			[]string{"verify"},
+			"",
+			2,
+			"",
+			`unknown command "verify"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			code := run(tt.args, strings.NewReader(tt.stdin), stdout, stderr)
+			if code != tt.wantCode {
+				t.Errorf("run() = %v, want %v, stderr %v", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("run() stdout = %v, want %v", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr.String(), tt.wantStderr) {
+				t.Errorf("run() stderr = %v, want %v", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	cognito "github.com/AyWa/jwt-cognito"
+	"github.com/AyWa/jwt-cognito/extauthz"
+	"github.com/AyWa/jwt-cognito/introspection"
+)
+
+func serveIntrospection(args []string, stderr io.Writer) error {
+	fs := newFlagSet("serve-introspection", stderr)
+	var f authFlags
+	f.register(fs)
+	// the client secrets are sent in clear without tls, so only listen
+	// on the loopback by default, for a proxy terminating tls
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	clientsFile := fs.String("clients-file", "", "file of the clients allowed to introspect, one `client_id:secret` per line")
+	tlsCert := fs.String("tls-cert", "", "certificate `file` to serve https, with --tls-key")
+	tlsKey := fs.String("tls-key", "", "private key `file` of --tls-cert")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *clientsFile == "" {
+		return errors.New("--clients-file is required")
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return errors.New("--tls-cert and --tls-key must be given together")
+	}
+	clients, err := readClients(*clientsFile)
+	if err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	auth, err := f.auth(cognito.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/introspect", introspection.New(auth, clients))
+	logger.Info("serving token introspection", "addr", *addr, "tls", *tlsCert != "")
+	return listenAndServe(*addr, mux, *tlsCert, *tlsKey)
+}
+
+func serveAuthz(args []string, stderr io.Writer) error {
+	fs := newFlagSet("serve-authz", stderr)
+	var f authFlags
+	f.register(fs)
+	addr := fs.String("addr", ":8080", "address to listen on")
+	var scopes, groups stringsFlag
+	fs.Var(&scopes, "scope", "scope the token must have, can be repeated")
+	fs.Var(&groups, "group", "group the user must be in, one of them when repeated")
+	headers := extauthz.DefaultHeaders
+	fs.StringVar(&headers.Sub, "sub-header", headers.Sub, "header of the sub, empty to disable")
+	fs.StringVar(&headers.Username, "username-header", headers.Username, "header of the username, empty to disable")
+	fs.StringVar(&headers.Groups, "groups-header", headers.Groups, "header of the groups, empty to disable")
+	fs.StringVar(&headers.Scopes, "scopes-header", headers.Scopes, "header of the scopes, empty to disable")
+	fs.StringVar(&headers.ClientID, "client-id-header", headers.ClientID, "header of the client id, empty to disable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+	auth, err := f.auth(cognito.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+	var opts []cognito.ValidateOption
+	if len(scopes) > 0 {
+		opts = append(opts, cognito.WithScopes(scopes...))
+	}
+	if len(groups) > 0 {
+		opts = append(opts, cognito.WithGroups(groups...))
+	}
+	h := extauthz.New(auth, opts...)
+	h.Headers = headers
+	logger.Info("serving authorization", "addr", *addr)
+	return listenAndServe(*addr, h, "", "")
+}
+
+// stringsFlag is a flag that can be repeated
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// listenAndServe serve https when certFile and keyFile are given
+func listenAndServe(addr string, handler http.Handler, certFile, keyFile string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	if certFile != "" {
+		return server.ListenAndServeTLS(certFile, keyFile)
+	}
+	return server.ListenAndServe()
+}
+
+// readClients read a file of `client_id:secret` lines, the empty lines and
+// the lines starting with # are ignored
+func readClients(name string) (map[string]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseClients(file)
+}
+
+func parseClients(r io.Reader) (map[string]string, error) {
+	clients := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, secret, ok := strings.Cut(line, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("clients file line %d: want client_id:secret", n)
+		}
+		clients[id] = secret
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("clients file has no client")
+	}
+	return clients, nil
+}
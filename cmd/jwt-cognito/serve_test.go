@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseClients(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"should parse the clients", "# legacy apps\nphp-app:s3cr3t\n\nscripts:pa:ss\n", map[string]string{"php-app": "s3cr3t", "scripts": "pa:ss"}, false},
+		{"should return error: no secret", "php-app\n", nil, true},
+		{"should return error: empty secret", "php-app:\n", nil, true},
+		{"should return error: no client", "# nobody\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseClients(strings.NewReader(tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseClients() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseClients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package cognitoprom is a prometheus implementation of cognito.Metrics.
+//
+//	metrics := cognitoprom.New()
+//	prometheus.MustRegister(metrics)
+//	auth := cognito.New("us-east-1", "us-east-1_XXXXXXX", cognito.WithMetrics(metrics))
+package cognitoprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics collects the measures of one or several cognito.Auth.
+// It implements both cognito.Metrics and prometheus.Collector
+type Metrics struct {
+	validations   *prometheus.CounterVec
+	fetchDuration prometheus.Histogram
+	fetchErrors   prometheus.Counter
+	cachedKeys    prometheus.Gauge
+	lastRefresh   prometheus.Gauge
+}
+
+// New create the metrics, they still need to be registered
+func New() *Metrics {
+	return &Metrics{
+		validations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cognito",
+			Name:      "validations_total",
+			Help:      "Number of token validations by outcome and token_use.",
+		}, []string{"outcome", "token_use"}),
+		fetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "cognito",
+			Name:      "jwks_fetch_duration_seconds",
+			Help:      "Duration of the jwks fetches, successful or not.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		fetchErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "cognito",
+			Name:      "jwks_fetch_errors_total",
+			Help:      "Number of jwks fetches that failed.",
+		}),
+		cachedKeys: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "cognito",
+			Name:      "jwks_cached_keys",
+			Help:      "Number of public keys in cache.",
+		}),
+		// the age of the keys is time() - cognito_jwks_last_refresh_timestamp_seconds,
+		// it is very large until the first successful fetch so an alert on it fires
+		lastRefresh: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "cognito",
+			Name:      "jwks_last_refresh_timestamp_seconds",
+			Help:      "Unix time of the last successful jwks fetch, 0 until the first one.",
+		}),
+	}
+}
+
+// ObserveValidation implements cognito.Metrics
+func (m *Metrics) ObserveValidation(outcome, tokenUse string) {
+	m.validations.WithLabelValues(outcome, tokenUse).Inc()
+}
+
+// ObserveFetch implements cognito.Metrics
+func (m *Metrics) ObserveFetch(duration time.Duration, err error) {
+	m.fetchDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.fetchErrors.Inc()
+		return
+	}
+	m.lastRefresh.SetToCurrentTime()
+}
+
+// ObserveCacheSize implements cognito.Metrics
+func (m *Metrics) ObserveCacheSize(size int) {
+	m.cachedKeys.Set(float64(size))
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.validations.Describe(ch)
+	m.fetchDuration.Describe(ch)
+	m.fetchErrors.Describe(ch)
+	m.cachedKeys.Describe(ch)
+	m.lastRefresh.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.validations.Collect(ch)
+	m.fetchDuration.Collect(ch)
+	m.fetchErrors.Collect(ch)
+	m.cachedKeys.Collect(ch)
+	m.lastRefresh.Collect(ch)
+}
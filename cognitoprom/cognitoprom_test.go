@@ -0,0 +1,56 @@
+package cognitoprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	cognito "github.com/AyWa/jwt-cognito"
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+// ensure Metrics can be given to cognito.WithMetrics
+var _ cognito.Metrics = &Metrics{}
+
+func TestMetrics(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	metrics := New()
+	if got := testutil.ToFloat64(metrics.lastRefresh); got != 0 {
+		t.Errorf("cognito_jwks_last_refresh_timestamp_seconds = %v before any fetch, want 0", got)
+	}
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(metrics)
+	auth := s.Auth(cognito.WithMetrics(metrics))
+
+	auth.ValidateToken(s.IDToken())
+	auth.ValidateToken(s.AccessToken())
+	auth.ValidateToken(s.AccessToken(cognitotest.WithExpiry(time.Now().Add(-time.Hour))))
+
+	want := `
+# HELP cognito_validations_total Number of token validations by outcome and token_use.
+# TYPE cognito_validations_total counter
+cognito_validations_total{outcome="expired",token_use="access"} 1
+cognito_validations_total{outcome="valid",token_use="access"} 1
+cognito_validations_total{outcome="valid",token_use="id"} 1
+# HELP cognito_jwks_fetch_errors_total Number of jwks fetches that failed.
+# TYPE cognito_jwks_fetch_errors_total counter
+cognito_jwks_fetch_errors_total 0
+# HELP cognito_jwks_cached_keys Number of public keys in cache.
+# TYPE cognito_jwks_cached_keys gauge
+cognito_jwks_cached_keys 1
+`
+	names := []string{"cognito_validations_total", "cognito_jwks_fetch_errors_total", "cognito_jwks_cached_keys"}
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), names...); err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(metrics, "cognito_jwks_fetch_duration_seconds"); got != 1 {
+		t.Errorf("cognito_jwks_fetch_duration_seconds count = %v, want 1", got)
+	}
+	if got := time.Since(time.Unix(int64(testutil.ToFloat64(metrics.lastRefresh)), 0)); got < 0 || got > time.Minute {
+		t.Errorf("cognito_jwks_last_refresh_timestamp_seconds is %v old", got)
+	}
+}
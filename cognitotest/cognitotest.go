@@ -0,0 +1,245 @@
+// Package cognitotest provides utilities for testing code that validate
+// cognito tokens with the cognito package.
+//
+// It generates an RSA key pair, serves the public key as a cognito shaped
+// jwks with an httptest.Server and mints id and access tokens signed with it.
+package cognitotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// Default values used by a new Server and the tokens it mints
+const (
+	DefaultRegion     = "us-east-1"
+	DefaultUserPoolID = "us-east-1_TEST00000"
+	DefaultClientID   = "16p6m803hdmmvqs0bbvinfb9pt"
+	DefaultKid        = "cognitotest-kid"
+	DefaultSub        = "3a9f2f3a-e659-41da-b116-0902d1f7d4ea"
+	DefaultUsername   = "test-user"
+	DefaultEmail      = "test-user@example.com"
+	DefaultScope      = "aws.cognito.signin.user.admin"
+	DefaultM2MScope   = "cognitotest/read"
+)
+
+// Server is a fake cognito user pool serving its jwks at the same path
+// as cognito does: /<userPoolID>/.well-known/jwks.json
+type Server struct {
+	*httptest.Server
+	Region     string
+	UserPoolID string
+	ClientID   string
+	Kid        string
+
+	privateKey *rsa.PrivateKey
+}
+
+// NewServer starts and returns a new Server.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("cognitotest: failed to generate rsa key: %v", err))
+	}
+	s := &Server{
+		Region:     DefaultRegion,
+		UserPoolID: DefaultUserPoolID,
+		ClientID:   DefaultClientID,
+		Kid:        DefaultKid,
+		privateKey: privateKey,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveJWKS))
+	return s
+}
+
+func (s *Server) serveJWKS(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/"+s.UserPoolID+"/.well-known/jwks.json" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.JWKS())
+}
+
+// JWKS returns the jwks document served by the server
+func (s *Server) JWKS() []byte {
+	publicKey := s.privateKey.PublicKey
+	jwks := map[string]interface{}{
+		"keys": []map[string]string{{
+			"alg": "RS256",
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+			"kid": s.Kid,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			"use": "sig",
+		}},
+	}
+	raw, err := json.Marshal(jwks)
+	if err != nil {
+		panic(fmt.Sprintf("cognitotest: failed to marshal jwks: %v", err))
+	}
+	return raw
+}
+
+// JWKSURL returns the url of the jwks document
+func (s *Server) JWKSURL() string {
+	return s.URL + "/" + s.UserPoolID + "/.well-known/jwks.json"
+}
+
+// Issuer returns the `iss` claim of the minted tokens, the same one a real
+// user pool would use
+func (s *Server) Issuer() string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", s.Region, s.UserPoolID)
+}
+
+// Auth returns a cognito.Auth wired to the server jwks
+func (s *Server) Auth(opts ...cognito.Option) *cognito.Auth {
+	opts = append([]cognito.Option{cognito.WithJWKSURL(s.JWKSURL())}, opts...)
+	return cognito.New(s.Region, s.UserPoolID, opts...)
+}
+
+// IDToken mints an id token with the claims a cognito id token would have.
+// The claims can be changed with opts
+func (s *Server) IDToken(opts ...TokenOption) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":              DefaultSub,
+		"aud":              s.ClientID,
+		"email_verified":   true,
+		"event_id":         "503b9285-75fc-429f-96fe-be7d08129e3e",
+		"token_use":        "id",
+		"auth_time":        now.Unix(),
+		"iss":              s.Issuer(),
+		"cognito:username": DefaultUsername,
+		"exp":              now.Add(time.Hour).Unix(),
+		"iat":              now.Unix(),
+		"email":            DefaultEmail,
+	}
+	return s.Token(claims, opts...)
+}
+
+// AccessToken mints an access token with the claims a cognito access token
+// would have. The claims can be changed with opts
+func (s *Server) AccessToken(opts ...TokenOption) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":       DefaultSub,
+		"event_id":  "503b9285-75fc-429f-96fe-be7d08129e3e",
+		"token_use": "access",
+		"scope":     DefaultScope,
+		"auth_time": now.Unix(),
+		"iss":       s.Issuer(),
+		"exp":       now.Add(time.Hour).Unix(),
+		"iat":       now.Unix(),
+		"jti":       "849850ea-c9fc-419e-b0e5-7eb46d9d65cc",
+		"client_id": s.ClientID,
+		"username":  DefaultUsername,
+	}
+	return s.Token(claims, opts...)
+}
+
+// M2MToken mints an access token as cognito issues with the client
+// credentials grant: the subject is the client and there is no username.
+// The claims can be changed with opts
+func (s *Server) M2MToken(opts ...TokenOption) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":       s.ClientID,
+		"token_use": "access",
+		"scope":     DefaultM2MScope,
+		"auth_time": now.Unix(),
+		"iss":       s.Issuer(),
+		"exp":       now.Add(time.Hour).Unix(),
+		"iat":       now.Unix(),
+		"jti":       "5f4b3c7e-2a1d-4e8b-9c6f-0d7a8b9c1e2f",
+		"client_id": s.ClientID,
+	}
+	return s.Token(claims, opts...)
+}
+
+// Token mints a token with exactly the given claims, after applying opts
+func (s *Server) Token(claims map[string]interface{}, opts ...TokenOption) string {
+	t := &token{kid: s.Kid, claims: jwt.MapClaims{}}
+	for k, v := range claims {
+		t.claims[k] = v
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodRS256, t.claims)
+	jwtToken.Header["kid"] = t.kid
+	signed, err := jwtToken.SignedString(s.privateKey)
+	if err != nil {
+		panic(fmt.Sprintf("cognitotest: failed to sign token: %v", err))
+	}
+	return signed
+}
+
+type token struct {
+	kid    string
+	claims jwt.MapClaims
+}
+
+// TokenOption change a minted token
+type TokenOption func(*token)
+
+// WithClaim set a claim, overriding the default one if any
+func WithClaim(name string, value interface{}) TokenOption {
+	return func(t *token) {
+		t.claims[name] = value
+	}
+}
+
+// WithClaims set several claims, overriding the default ones if any
+func WithClaims(claims map[string]interface{}) TokenOption {
+	return func(t *token) {
+		for k, v := range claims {
+			t.claims[k] = v
+		}
+	}
+}
+
+// WithoutClaim remove a claim
+func WithoutClaim(name string) TokenOption {
+	return func(t *token) {
+		delete(t.claims, name)
+	}
+}
+
+// WithExpiry set the `exp` claim
+func WithExpiry(exp time.Time) TokenOption {
+	return WithClaim("exp", exp.Unix())
+}
+
+// WithKid set the kid of the token header, for example to mint a token
+// signed with a key that is not in the jwks
+func WithKid(kid string) TokenOption {
+	return func(t *token) {
+		t.kid = kid
+	}
+}
+
+// WithGroups set the `cognito:groups` claim
+func WithGroups(groups ...string) TokenOption {
+	return WithClaim("cognito:groups", groups)
+}
+
+// WithAccessTokenHash set the `at_hash` claim of an id token for the access
+// token issued with it
+func WithAccessTokenHash(accessToken string) TokenOption {
+	sum := sha256.Sum256([]byte(accessToken))
+	return WithClaim("at_hash", base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]))
+}
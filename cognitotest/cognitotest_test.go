@@ -0,0 +1,67 @@
+package cognitotest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServer_Auth(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	auth := s.Auth()
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"should succeed: id token", s.IDToken(), false},
+		{"should succeed: access token", s.AccessToken(), false},
+		{"should succeed: custom claims", s.IDToken(WithGroups("admin"), WithClaim("custom:tenant_id", "acme")), false},
+		{"should return error: expired token", s.IDToken(WithExpiry(time.Now().Add(-time.Minute))), true},
+		{"should return error: unknown kid", s.IDToken(WithKid("unknown")), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.ValidateToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Auth.ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServer_IDToken(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	payload, err := s.Auth().ValidateIDToken(s.IDToken(WithClaim("cognito:username", "marc")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Username != "marc" || payload.Iss != s.Issuer() || payload.Aud != s.ClientID || payload.TokenUse != "id" {
+		t.Errorf("Auth.ValidateIDToken() = %+v", payload)
+	}
+}
+
+func TestServer_AccessToken(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	payload, err := s.Auth().ValidateAccessToken(s.AccessToken(WithoutClaim("username")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Username != "" || payload.ClientID != s.ClientID || payload.TokenUse != "access" {
+		t.Errorf("Auth.ValidateAccessToken() = %+v", payload)
+	}
+}
+
+func TestServer_M2MToken(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	payload, err := s.Auth().ValidateM2MToken(s.M2MToken(), s.ClientID, []string{DefaultM2MScope})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Sub != s.ClientID || payload.Username != "" || payload.Scope != DefaultM2MScope {
+		t.Errorf("Auth.ValidateM2MToken() = %+v", payload)
+	}
+}
@@ -0,0 +1,112 @@
+// Package extauthz implements an authorization service validating cognito
+// tokens at the edge, so the services behind the proxy do not have to.
+//
+// It follows the contract of both the Envoy ext_authz http service and the
+// nginx auth_request module: the proxy sends the headers of the original
+// request, a 200 allows it and the headers of the response describe the user
+// to the upstream; a 401 or 403 denies it, and a 503 when the keys of the pool
+// can not be fetched.
+// see https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_filters/ext_authz_filter
+// and https://nginx.org/en/docs/http/ngx_http_auth_request_module.html
+package extauthz
+
+import (
+	"net/http"
+	"strings"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// Headers are the names of the headers returned to the proxy for a valid
+// token. An empty name disables the header
+type Headers struct {
+	Sub      string
+	Username string
+	// Groups is the comma separated cognito:groups
+	Groups string
+	// Scopes is the space separated scope of an access token
+	Scopes   string
+	ClientID string
+}
+
+// DefaultHeaders are the headers returned by a new Handler
+var DefaultHeaders = Headers{
+	Sub:      "X-Auth-Sub",
+	Username: "X-Auth-Username",
+	Groups:   "X-Auth-Groups",
+	Scopes:   "X-Auth-Scopes",
+	ClientID: "X-Auth-Client-Id",
+}
+
+// Handler is the authorization service, it answers to any path and method
+type Handler struct {
+	// Headers can be changed before the first use, DefaultHeaders by default
+	Headers Headers
+
+	auth *cognito.Auth
+	opts []cognito.ValidateOption
+}
+
+// New create an authorization service validating the bearer token of the
+// requests with auth. opts are the rules a token needs to be allowed, for
+// example cognito.WithScopes or cognito.WithGroups
+func New(auth *cognito.Auth, opts ...cognito.ValidateOption) *Handler {
+	return &Handler{Headers: DefaultHeaders, auth: auth, opts: opts}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tokenString, ok := cognito.BearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	claims, err := h.auth.ValidateTokenContext(r.Context(), tokenString, h.opts...)
+	if err != nil {
+		challenge, code := cognito.Challenge(err)
+		if challenge != "" {
+			w.Header().Set("WWW-Authenticate", challenge)
+		}
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	h.setHeaders(w.Header(), claims)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) setHeaders(header http.Header, claims map[string]interface{}) {
+	username := stringClaim(claims, "username")
+	if username == "" {
+		username = stringClaim(claims, "cognito:username")
+	}
+	clientID := stringClaim(claims, "client_id")
+	if clientID == "" {
+		clientID = stringClaim(claims, "aud")
+	}
+	var groups []string
+	if raw, ok := claims["cognito:groups"].([]interface{}); ok {
+		for _, g := range raw {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+	set(header, h.Headers.Sub, stringClaim(claims, "sub"))
+	set(header, h.Headers.Username, username)
+	set(header, h.Headers.Groups, strings.Join(groups, ","))
+	set(header, h.Headers.Scopes, stringClaim(claims, "scope"))
+	set(header, h.Headers.ClientID, clientID)
+}
+
+// set the header even when the value is empty, so the proxy never forwards
+// a value sent by the client
+func set(header http.Header, name, value string) {
+	if name != "" {
+		header.Set(name, value)
+	}
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
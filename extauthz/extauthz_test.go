@@ -0,0 +1,157 @@
+package extauthz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cognito "github.com/AyWa/jwt-cognito"
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func TestHandler(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	tests := []struct {
+		name          string
+		opts          []cognito.ValidateOption
+		authorization string
+		wantCode      int
+		wantHeaders   map[string]string
+		wantChallenge string
+	}{
+		{
+			"should allow an access token",
+			nil,
+			"Bearer " + s.AccessToken(cognitotest.WithGroups("admin", "users")),
+			http.StatusOK,
+			map[string]string{
+				"X-Auth-Sub":       cognitotest.DefaultSub,
+				"X-Auth-Username":  cognitotest.DefaultUsername,
+				"X-Auth-Groups":    "admin,users",
+				"X-Auth-Scopes":    cognitotest.DefaultScope,
+				"X-Auth-Client-Id": s.ClientID,
+			},
+			"",
+		},
+		{
+			"should allow an id token",
+			nil,
+			"Bearer " + s.IDToken(),
+			http.StatusOK,
+			map[string]string{
+				"X-Auth-Username":  cognitotest.DefaultUsername,
+				"X-Auth-Groups":    "",
+				"X-Auth-Scopes":    "",
+				"X-Auth-Client-Id": s.ClientID,
+			},
+			"",
+		},
+		{
+			"should allow a machine to machine token",
+			[]cognito.ValidateOption{cognito.WithScopes(cognitotest.DefaultM2MScope)},
+			"Bearer " + s.M2MToken(),
+			http.StatusOK,
+			map[string]string{
+				"X-Auth-Sub":      s.ClientID,
+				"X-Auth-Username": "",
+				"X-Auth-Scopes":   cognitotest.DefaultM2MScope,
+			},
+			"",
+		},
+		{
+			"should deny a request without token",
+			nil,
+			"",
+			http.StatusUnauthorized,
+			nil,
+			"Bearer",
+		},
+		{
+			"should deny an expired token",
+			nil,
+			"Bearer " + s.AccessToken(cognitotest.WithExpiry(time.Now().Add(-time.Minute))),
+			http.StatusUnauthorized,
+			nil,
+			`Bearer error="invalid_token"`,
+		},
+		{
+			"should forbid a token without the scope",
+			[]cognito.ValidateOption{cognito.WithScopes("orders/write")},
+			"Bearer " + s.AccessToken(),
+			http.StatusForbidden,
+			nil,
+			`Bearer error="insufficient_scope", scope="orders/write"`,
+		},
+		{
+			"should forbid a user outside of the groups",
+			[]cognito.ValidateOption{cognito.WithGroups("admin")},
+			"Bearer " + s.AccessToken(cognitotest.WithGroups("users")),
+			http.StatusForbidden,
+			nil,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(s.Auth(), tt.opts...)
+			// envoy prefix the path of the original request, nginx send a sub request
+			r := httptest.NewRequest(http.MethodGet, "/authz/orders/42", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Handler code = %v, want %v", w.Code, tt.wantCode)
+			}
+			for name, want := range tt.wantHeaders {
+				if got, ok := w.Header()[name]; !ok || got[0] != want {
+					t.Errorf("Handler header %v = %v, want %v", name, got, want)
+				}
+			}
+			if tt.wantCode != http.StatusOK && w.Header().Get("X-Auth-Sub") != "" {
+				t.Error("Handler returned the user of a denied request")
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("Handler WWW-Authenticate = %v, want %v", got, tt.wantChallenge)
+			}
+		})
+	}
+}
+
+func TestHandler_fetchError(t *testing.T) {
+	s := cognitotest.NewServer()
+	auth := s.Auth()
+	token := s.AccessToken()
+	// the keys can not be fetched, the token is not known to be invalid
+	s.Close()
+	r := httptest.NewRequest(http.MethodGet, "/authz/orders/42", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	New(auth).ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Handler code = %v, want %v", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got != "" {
+		t.Errorf("Handler WWW-Authenticate = %v, want none", got)
+	}
+}
+
+func TestHandler_Headers(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	h := New(s.Auth())
+	h.Headers = Headers{Username: "X-User"}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+s.AccessToken())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("X-User"); got != cognitotest.DefaultUsername {
+		t.Errorf("Handler header X-User = %v, want %v", got, cognitotest.DefaultUsername)
+	}
+	if _, ok := w.Header()["X-Auth-Sub"]; ok {
+		t.Error("Handler returned a disabled header")
+	}
+}
@@ -0,0 +1,50 @@
+package cognito
+
+import "github.com/dgrijalva/jwt-go"
+
+// Hooks are callbacks on the Auth lifecycle events, see WithHooks.
+// They are called synchronously from the validation, so they should not
+// block. Every callback is optional
+type Hooks struct {
+	// OnKeysRefreshed is called when a jwks fetch changed the cached keys,
+	// for example on a key rotation. It is not called when the jwks is the
+	// same as before
+	OnKeysRefreshed func(old, new []Key)
+	// OnValidationFailure is called for every token that is not valid.
+	// header is a copy of the token header, nil if the token could not be decoded
+	OnValidationFailure func(err error, header map[string]interface{})
+	// OnUnknownKid is called when the kid of a token is not in the jwks,
+	// even after a refresh
+	OnUnknownKid func(kid string)
+}
+
+// sameKeys compare the jwk values, the parsed public keys come from them
+func sameKeys(a, b map[string]*awsWellKnowKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for kid, keyA := range a {
+		keyB, ok := b[kid]
+		if !ok {
+			return false
+		}
+		if keyA.Alg != keyB.Alg || keyA.Kty != keyB.Kty || keyA.Use != keyB.Use ||
+			keyA.E != keyB.E || keyA.N != keyB.N ||
+			keyA.Crv != keyB.Crv || keyA.X != keyB.X || keyA.Y != keyB.Y {
+			return false
+		}
+	}
+	return true
+}
+
+// tokenHeader copy the header so a hook can not change the token
+func tokenHeader(token *jwt.Token) map[string]interface{} {
+	if token == nil {
+		return nil
+	}
+	header := make(map[string]interface{}, len(token.Header))
+	for k, v := range token.Header {
+		header[k] = v
+	}
+	return header
+}
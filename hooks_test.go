@@ -0,0 +1,71 @@
+package cognito
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestAuth_hooks(t *testing.T) {
+	srv, setKeys := newTestRotatingJWKS(t)
+	var refreshed [][]Key
+	var failures []error
+	var headers []map[string]interface{}
+	var unknownKids []string
+	aws := New("", "", WithJWKSURL(srv.URL), WithHooks(Hooks{
+		OnKeysRefreshed: func(old, new []Key) {
+			refreshed = append(refreshed, old, new)
+		},
+		OnValidationFailure: func(err error, header map[string]interface{}) {
+			failures = append(failures, err)
+			headers = append(headers, header)
+		},
+		OnUnknownKid: func(kid string) {
+			unknownKids = append(unknownKids, kid)
+		},
+	}))
+	claims := jwt.MapClaims{"token_use": "id", "exp": 9.56034296e+09}
+	key1 := Key{Kid: "kid-1", Alg: "RS256", Kty: "RSA", Use: "sig", Bits: 2048}
+	key2 := Key{Kid: "kid-2", Alg: "ES256", Kty: "EC", Use: "sig", Crv: "P-256", Bits: 256}
+
+	setKeys(testJWK("kid-1", "RS256", &testRSAKey.PublicKey))
+	if _, err := aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-1", testRSAKey, claims)); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]Key{{}, {key1}}; !reflect.DeepEqual(refreshed, want) {
+		t.Errorf("OnKeysRefreshed() = %v, want %v", refreshed, want)
+	}
+
+	// same jwks: the kid is still unknown but the keys did not change
+	refreshed = nil
+	aws.ValidateToken(testSign(jwt.SigningMethodES256, "kid-2", testECKey, claims))
+	if refreshed != nil {
+		t.Errorf("OnKeysRefreshed() = %v, want no call", refreshed)
+	}
+	if want := []string{"kid-2"}; !reflect.DeepEqual(unknownKids, want) {
+		t.Errorf("OnUnknownKid() = %v, want %v", unknownKids, want)
+	}
+	if len(failures) != 1 || !errors.Is(failures[0], ErrKidNotFound) {
+		t.Errorf("OnValidationFailure() = %v, want ErrKidNotFound", failures)
+	}
+	if headers[0]["kid"] != "kid-2" || headers[0]["alg"] != "ES256" {
+		t.Errorf("OnValidationFailure() header = %v", headers[0])
+	}
+
+	// rotation
+	setKeys(testJWK("kid-2", "ES256", &testECKey.PublicKey))
+	if _, err := aws.ValidateToken(testSign(jwt.SigningMethodES256, "kid-2", testECKey, claims)); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]Key{{key1}, {key2}}; !reflect.DeepEqual(refreshed, want) {
+		t.Errorf("OnKeysRefreshed() = %v, want %v", refreshed, want)
+	}
+
+	// malformed token has no header
+	aws.ValidateToken("xx.yy")
+	if len(headers) != 2 || headers[1] != nil {
+		t.Errorf("OnValidationFailure() header = %v, want nil", headers)
+	}
+}
@@ -0,0 +1,80 @@
+package cognito
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Identity is an entry of the `identities` claim, present when the user
+// signed in through an external identity provider (Google, SAML, OIDC...)
+type Identity struct {
+	UserID       string    `mapstructure:"userId"`
+	ProviderName string    `mapstructure:"providerName"`
+	ProviderType string    `mapstructure:"providerType"`
+	Issuer       string    `mapstructure:"issuer"`
+	Primary      bool      `mapstructure:"primary"`
+	DateCreated  time.Time `mapstructure:"dateCreated"`
+}
+
+// IsFederated return true when the user signed in through an external
+// identity provider
+func (p *IDTokenPayload) IsFederated() bool {
+	return len(p.Identities) > 0
+}
+
+// PrimaryProvider return the name of the identity provider of the primary
+// identity, or of the first one if none is marked as primary.
+// It is empty when the user is not federated
+func (p *IDTokenPayload) PrimaryProvider() string {
+	for _, identity := range p.Identities {
+		if identity.Primary {
+			return identity.ProviderName
+		}
+	}
+	if len(p.Identities) > 0 {
+		return p.Identities[0].ProviderName
+	}
+	return ""
+}
+
+// convertIdentities normalize the identities claim so it can be decoded.
+// cognito gives it either as an array or as a JSON string, and the primary
+// and dateCreated fields are strings
+func convertIdentities(raw map[string]interface{}) {
+	if s, ok := raw["identities"].(string); ok {
+		var identities []interface{}
+		if err := json.Unmarshal([]byte(s), &identities); err != nil {
+			delete(raw, "identities")
+			return
+		}
+		raw["identities"] = identities
+	}
+	identities, ok := raw["identities"].([]interface{})
+	if !ok {
+		return
+	}
+	for _, identity := range identities {
+		values, ok := identity.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if primary, ok := values["primary"].(string); ok {
+			values["primary"], _ = strconv.ParseBool(primary)
+		}
+		// dateCreated is in milliseconds
+		switch dateCreated := values["dateCreated"].(type) {
+		case string:
+			if ms, err := strconv.ParseInt(dateCreated, 10, 64); err == nil {
+				values["dateCreated"] = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+			} else {
+				delete(values, "dateCreated")
+			}
+		case float64:
+			values["dateCreated"] = time.Unix(0, int64(dateCreated)*int64(time.Millisecond)).UTC()
+		}
+		if values["issuer"] == nil {
+			delete(values, "issuer")
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package cognito
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestAuth_ValidateIDToken_identities(t *testing.T) {
+	keys := mustParseKeys(map[string]*awsWellKnowKey{"RS256": testJWK("RS256", "RS256", &testRSAKey.PublicKey)})
+	dateCreated := time.Date(2020, 3, 5, 12, 45, 45, 678000000, time.UTC)
+	google := Identity{
+		UserID:       "109876543210987654321",
+		ProviderName: "Google",
+		ProviderType: "Google",
+		Primary:      true,
+		DateCreated:  dateCreated,
+	}
+	saml := Identity{
+		UserID:       "marc@corp.example.com",
+		ProviderName: "CorpSAML",
+		ProviderType: "SAML",
+		DateCreated:  dateCreated,
+	}
+	tests := []struct {
+		name          string
+		identities    interface{}
+		want          []Identity
+		wantFederated bool
+		wantProvider  string
+	}{
+		{
+			"should decode an array",
+			[]interface{}{
+				map[string]interface{}{"userId": "marc@corp.example.com", "providerName": "CorpSAML", "providerType": "SAML", "issuer": "urn:corp", "primary": "false", "dateCreated": "1583412345678"},
+				map[string]interface{}{"userId": "109876543210987654321", "providerName": "Google", "providerType": "Google", "issuer": nil, "primary": "true", "dateCreated": "1583412345678"},
+			},
+			[]Identity{{UserID: saml.UserID, ProviderName: saml.ProviderName, ProviderType: saml.ProviderType, Issuer: "urn:corp", DateCreated: dateCreated}, google},
+			true,
+			"Google",
+		},
+		{
+			"should decode a JSON string",
+			`[{"userId":"109876543210987654321","providerName":"Google","providerType":"Google","issuer":null,"primary":"true","dateCreated":"1583412345678"}]`,
+			[]Identity{google},
+			true,
+			"Google",
+		},
+		{
+			"should use the first identity without primary",
+			[]interface{}{
+				map[string]interface{}{"userId": "marc@corp.example.com", "providerName": "CorpSAML", "providerType": "SAML", "primary": "false", "dateCreated": 1583412345678.0},
+			},
+			[]Identity{saml},
+			true,
+			"CorpSAML",
+		},
+		{
+			"should not be federated without identities",
+			nil,
+			nil,
+			false,
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := jwt.MapClaims{"token_use": "id", "exp": 9.56034296e+09}
+			if tt.identities != nil {
+				claims["identities"] = tt.identities
+			}
+			aws := &Auth{awsKeys: keys}
+			got, err := aws.ValidateIDToken(testSign(jwt.SigningMethodRS256, "RS256", testRSAKey, claims))
+			if err != nil {
+				t.Fatalf("Auth.ValidateIDToken() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Identities, tt.want) {
+				t.Errorf("IDTokenPayload.Identities = %+v, want %+v", got.Identities, tt.want)
+			}
+			if got.IsFederated() != tt.wantFederated {
+				t.Errorf("IDTokenPayload.IsFederated() = %v, want %v", got.IsFederated(), tt.wantFederated)
+			}
+			if got.PrimaryProvider() != tt.wantProvider {
+				t.Errorf("IDTokenPayload.PrimaryProvider() = %v, want %v", got.PrimaryProvider(), tt.wantProvider)
+			}
+		})
+	}
+}
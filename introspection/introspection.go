@@ -0,0 +1,143 @@
+// Package introspection implements a token introspection endpoint on top of
+// cognito.Auth, for the services that can not validate the tokens themselves.
+// The response follows https://www.rfc-editor.org/rfc/rfc7662
+package introspection
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// Response is the introspection response. Only Active is set for a token
+// that is not valid. TokenUse and Groups are cognito extensions
+type Response struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       string   `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	TokenUse  string   `json:"token_use,omitempty"`
+	Groups    []string `json:"cognito:groups,omitempty"`
+}
+
+// Handler is the introspection endpoint. It only answers to the clients
+// authenticated with http basic auth, and validates the `token` form
+// parameter with an Auth
+type Handler struct {
+	auth    *cognito.Auth
+	clients map[string]string
+	opts    []cognito.ValidateOption
+}
+
+// New create an introspection endpoint validating the tokens with auth.
+// clients maps the id of the clients allowed to call it to their secret.
+// opts are the extra checks a token needs to be active
+func New(auth *cognito.Auth, clients map[string]string, opts ...cognito.ValidateOption) *Handler {
+	return &Handler{auth: auth, clients: clients, opts: opts}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticate(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	token := r.PostFormValue("token")
+	if token == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	claims, err := h.auth.ValidateTokenContext(r.Context(), token, h.opts...)
+	if err != nil {
+		// the reason is not given to the client, it is in the Auth logs and metrics
+		writeJSON(w, http.StatusOK, &Response{Active: false})
+		return
+	}
+	writeJSON(w, http.StatusOK, newResponse(claims))
+}
+
+// authenticate check the client credentials of the basic auth, they are
+// form encoded, see https://www.rfc-editor.org/rfc/rfc6749#section-2.3.1
+func (h *Handler) authenticate(r *http.Request) bool {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+	id, err := url.QueryUnescape(id)
+	if err != nil {
+		return false
+	}
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return false
+	}
+	want, ok := h.clients[id]
+	return ok && want != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(want)) == 1
+}
+
+// newResponse map the claims of a cognito id or access token
+func newResponse(claims map[string]interface{}) *Response {
+	resp := &Response{
+		Active:    true,
+		TokenType: "Bearer",
+		Scope:     stringClaim(claims, "scope"),
+		ClientID:  stringClaim(claims, "client_id"),
+		Username:  stringClaim(claims, "username"),
+		Exp:       intClaim(claims, "exp"),
+		Iat:       intClaim(claims, "iat"),
+		Nbf:       intClaim(claims, "nbf"),
+		Sub:       stringClaim(claims, "sub"),
+		Aud:       stringClaim(claims, "aud"),
+		Iss:       stringClaim(claims, "iss"),
+		Jti:       stringClaim(claims, "jti"),
+		TokenUse:  stringClaim(claims, "token_use"),
+	}
+	// an id token has the client in aud and the username in cognito:username
+	if resp.ClientID == "" {
+		resp.ClientID = resp.Aud
+	}
+	if resp.Username == "" {
+		resp.Username = stringClaim(claims, "cognito:username")
+	}
+	if groups, ok := claims["cognito:groups"].([]interface{}); ok {
+		for _, g := range groups {
+			if s, ok := g.(string); ok {
+				resp.Groups = append(resp.Groups, s)
+			}
+		}
+	}
+	return resp
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+func intClaim(claims map[string]interface{}, name string) int64 {
+	f, _ := claims[name].(float64)
+	return int64(f)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,123 @@
+package introspection
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func TestHandler(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	h := New(s.Auth(), map[string]string{"php-app": "s3cr3t/+"})
+	tests := []struct {
+		name     string
+		method   string
+		user     string
+		password string
+		token    string
+		wantCode int
+		want     *Response
+	}{
+		{
+			"should introspect an access token",
+			http.MethodPost, "php-app", "s3cr3t/+",
+			s.AccessToken(cognitotest.WithExpiry(exp), cognitotest.WithGroups("admin")),
+			http.StatusOK,
+			&Response{
+				Active: true, Scope: cognitotest.DefaultScope, ClientID: s.ClientID, Username: cognitotest.DefaultUsername,
+				TokenType: "Bearer", Exp: exp.Unix(), Iat: exp.Add(-time.Hour).Unix(), Sub: cognitotest.DefaultSub, Iss: s.Issuer(),
+				Jti: "849850ea-c9fc-419e-b0e5-7eb46d9d65cc", TokenUse: "access", Groups: []string{"admin"},
+			},
+		},
+		{
+			"should introspect an id token",
+			http.MethodPost, "php-app", "s3cr3t/+",
+			s.IDToken(cognitotest.WithExpiry(exp)),
+			http.StatusOK,
+			&Response{
+				Active: true, ClientID: s.ClientID, Username: cognitotest.DefaultUsername,
+				TokenType: "Bearer", Exp: exp.Unix(), Iat: exp.Add(-time.Hour).Unix(), Sub: cognitotest.DefaultSub, Aud: s.ClientID, Iss: s.Issuer(),
+				TokenUse: "id",
+			},
+		},
+		{
+			"should return an inactive token",
+			http.MethodPost, "php-app", "s3cr3t/+",
+			s.AccessToken(cognitotest.WithExpiry(time.Now().Add(-time.Minute))),
+			http.StatusOK,
+			&Response{Active: false},
+		},
+		{
+			"should reject a request without token",
+			http.MethodPost, "php-app", "s3cr3t/+",
+			"",
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"should reject a wrong secret",
+			http.MethodPost, "php-app", "wrong",
+			s.AccessToken(),
+			http.StatusUnauthorized,
+			nil,
+		},
+		{
+			"should reject an unknown client",
+			http.MethodPost, "unknown", "s3cr3t/+",
+			s.AccessToken(),
+			http.StatusUnauthorized,
+			nil,
+		},
+		{
+			"should reject an unauthenticated request",
+			http.MethodPost, "", "",
+			s.AccessToken(),
+			http.StatusUnauthorized,
+			nil,
+		},
+		{
+			"should only accept POST",
+			http.MethodGet, "php-app", "s3cr3t/+",
+			"",
+			http.StatusMethodNotAllowed,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"token": {tt.token}}
+			r := httptest.NewRequest(tt.method, "/introspect", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.user != "" {
+				r.SetBasicAuth(url.QueryEscape(tt.user), url.QueryEscape(tt.password))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Handler code = %v, want %v: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Handler has no WWW-Authenticate header")
+			}
+			if tt.want == nil {
+				return
+			}
+			got := &Response{}
+			if err := json.NewDecoder(w.Body).Decode(got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Handler response = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package cognito
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// The logs never contain the raw token nor its signature, only the kid,
+// the token_use and the reason of the failure
+
+func (aws *Auth) logKeysLoaded(ctx context.Context, url string, previous, loaded map[string]*awsWellKnowKey) {
+	if aws.logger == nil {
+		return
+	}
+	aws.logger.LogAttrs(ctx, slog.LevelInfo, "cognito: keys loaded",
+		slog.String("url", url),
+		slog.Int("count", len(loaded)),
+	)
+	for kid := range loaded {
+		if _, ok := previous[kid]; !ok {
+			aws.logger.LogAttrs(ctx, slog.LevelInfo, "cognito: kid added", slog.String("kid", kid))
+		}
+	}
+	for kid := range previous {
+		if _, ok := loaded[kid]; !ok {
+			aws.logger.LogAttrs(ctx, slog.LevelInfo, "cognito: kid removed", slog.String("kid", kid))
+		}
+	}
+}
+
+func (aws *Auth) logKeyRejected(ctx context.Context, kid string, err error) {
+	if aws.logger == nil {
+		return
+	}
+	aws.logger.LogAttrs(ctx, slog.LevelWarn, "cognito: key rejected",
+		slog.String("kid", kid),
+		slog.String("error", err.Error()),
+	)
+}
+
+func (aws *Auth) logFetchError(ctx context.Context, url string, err error) {
+	if aws.logger == nil {
+		return
+	}
+	aws.logger.LogAttrs(ctx, slog.LevelError, "cognito: fetch error",
+		slog.String("url", url),
+		slog.String("error", err.Error()),
+	)
+}
+
+func (aws *Auth) logValidationFailed(ctx context.Context, token *jwt.Token, reason, tokenUse string, err error) {
+	if aws.logger == nil {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("reason", reason),
+		slog.String("token_use", tokenUse),
+		slog.String("error", err.Error()),
+	}
+	if token != nil {
+		if kid, ok := token.Header["kid"].(string); ok {
+			attrs = append(attrs, slog.String("kid", kid))
+		}
+	}
+	aws.logger.LogAttrs(ctx, slog.LevelInfo, "cognito: validation failed", attrs...)
+}
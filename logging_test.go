@@ -0,0 +1,103 @@
+package cognito
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// newTestRotatingJWKS serve the keys given to the returned function
+func newTestRotatingJWKS(t *testing.T) (*httptest.Server, func(keys ...*awsWellKnowKey)) {
+	var mu sync.Mutex
+	var current []*awsWellKnowKey
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(awsWellKnowKeys{Keys: current})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func(keys ...*awsWellKnowKey) {
+		mu.Lock()
+		current = keys
+		mu.Unlock()
+	}
+}
+
+func logMessages(buf *bytes.Buffer) []map[string]interface{} {
+	var messages []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		m := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &m); err == nil {
+			messages = append(messages, m)
+		}
+	}
+	buf.Reset()
+	return messages
+}
+
+func TestAuth_logging(t *testing.T) {
+	srv, setKeys := newTestRotatingJWKS(t)
+	buf := &bytes.Buffer{}
+	aws := New("", "", WithJWKSURL(srv.URL), WithLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+	claims := jwt.MapClaims{"token_use": "id", "exp": 9.56034296e+09}
+
+	// first key set
+	setKeys(testJWK("kid-1", "RS256", &testRSAKey.PublicKey), &awsWellKnowKey{Kid: "kid-bad", Kty: "RSA", E: "AQAB"})
+	aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-1", testRSAKey, claims))
+	got := logMessages(buf)
+	want := []string{"cognito: key rejected", "cognito: keys loaded", "cognito: kid added"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v logs, want %v: %v", len(got), len(want), got)
+	}
+	for i, msg := range want {
+		if got[i]["msg"] != msg {
+			t.Errorf("log %v = %v, want %v", i, got[i]["msg"], msg)
+		}
+	}
+
+	// key rotation
+	setKeys(testJWK("kid-2", "RS256", &testRSAKey.PublicKey))
+	aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-2", testRSAKey, claims))
+	got = logMessages(buf)
+	var added, removed string
+	for _, m := range got {
+		switch m["msg"] {
+		case "cognito: kid added":
+			added = m["kid"].(string)
+		case "cognito: kid removed":
+			removed = m["kid"].(string)
+		}
+	}
+	if added != "kid-2" || removed != "kid-1" {
+		t.Errorf("kid added = %v, kid removed = %v, want kid-2 and kid-1", added, removed)
+	}
+
+	// validation failure never log the token
+	expired := testSign(jwt.SigningMethodRS256, "kid-2", testRSAKey, jwt.MapClaims{"token_use": "access", "exp": 1})
+	aws.ValidateToken(expired)
+	raw := buf.String()
+	got = logMessages(buf)
+	if len(got) != 1 || got[0]["msg"] != "cognito: validation failed" || got[0]["reason"] != "expired" || got[0]["token_use"] != "access" || got[0]["kid"] != "kid-2" {
+		t.Errorf("got %v", got)
+	}
+	for _, part := range strings.Split(expired, ".") {
+		if strings.Contains(raw, part) {
+			t.Errorf("the log contains a part of the token: %v", raw)
+		}
+	}
+
+	// fetch error
+	srv.Close()
+	aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-3", testRSAKey, claims))
+	got = logMessages(buf)
+	if len(got) != 2 || got[0]["msg"] != "cognito: fetch error" || got[1]["reason"] != "key_fetch_error" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,31 @@
+package cognito
+
+import (
+	"context"
+)
+
+// ValidateM2MToken validate an access token obtained with the client
+// credentials grant by the app client clientID. The token must have all the
+// scopes, usually the custom scopes of a resource server (`orders/read`).
+// A token issued to a user, that has a username, is rejected with ErrNotM2MToken
+// see https://docs.aws.amazon.com/cognito/latest/developerguide/cognito-user-pools-define-resource-servers.html
+func (aws *Auth) ValidateM2MToken(accessToken, clientID string, scopes []string, opts ...ValidateOption) (*AccessTokenPayload, error) {
+	return aws.ValidateM2MTokenContext(context.Background(), accessToken, clientID, scopes, opts...)
+}
+
+// ValidateM2MTokenContext is similar as ValidateM2MToken, see ValidateTokenContext
+func (aws *Auth) ValidateM2MTokenContext(ctx context.Context, accessToken, clientID string, scopes []string, opts ...ValidateOption) (*AccessTokenPayload, error) {
+	opts = append([]ValidateOption{m2mToken, WithClientID(clientID), WithScopes(scopes...)}, opts...)
+	return aws.ValidateAccessTokenContext(ctx, accessToken, opts...)
+}
+
+// m2mToken check that the token is an access token without user
+func m2mToken(token TokenInfo, claims map[string]interface{}) error {
+	if claims["token_use"] != "access" {
+		return ErrNotM2MToken
+	}
+	if _, ok := claims["username"]; ok {
+		return ErrNotM2MToken
+	}
+	return nil
+}
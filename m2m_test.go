@@ -0,0 +1,40 @@
+package cognito_test
+
+import (
+	"errors"
+	"testing"
+
+	cognito "github.com/AyWa/jwt-cognito"
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func TestAuth_ValidateM2MToken(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	auth := s.Auth()
+	scopes := cognitotest.WithClaim("scope", "orders/read orders/write")
+	tests := []struct {
+		name     string
+		token    string
+		clientID string
+		scopes   []string
+		wantErr  error
+	}{
+		{"should succeed: all scopes", s.M2MToken(scopes), s.ClientID, []string{"orders/write", "orders/read"}, nil},
+		{"should succeed: no scope required", s.M2MToken(scopes), s.ClientID, nil, nil},
+		{"should return error: missing scope", s.M2MToken(scopes), s.ClientID, []string{"orders/delete"}, cognito.ErrInsufficientScope},
+		{"should return error: scope prefix", s.M2MToken(scopes), s.ClientID, []string{"orders"}, cognito.ErrInsufficientScope},
+		{"should return error: other client", s.M2MToken(scopes), "other-client", nil, cognito.ErrInvalidClientID},
+		{"should return error: no client_id", s.M2MToken(cognitotest.WithoutClaim("client_id")), s.ClientID, nil, cognito.ErrInvalidClientID},
+		{"should return error: user access token", s.AccessToken(), s.ClientID, nil, cognito.ErrNotM2MToken},
+		{"should return error: id token", s.IDToken(), s.ClientID, nil, cognito.ErrNotM2MToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.ValidateM2MToken(tt.token, tt.clientID, tt.scopes)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("Auth.ValidateM2MToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
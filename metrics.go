@@ -0,0 +1,130 @@
+package cognito
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Metrics receive the measures of an Auth, see WithMetrics.
+// The cognitoprom subpackage provides a prometheus implementation
+type Metrics interface {
+	// ObserveValidation is called after each validation.
+	// outcome is one of: valid, malformed, no_kid, unknown_kid, invalid_alg,
+	// key_fetch_error, invalid_signature, expired, not_yet_valid,
+	// invalid_issuer, invalid_nonce, invalid_at_hash, auth_too_old,
+	// invalid_client_id, insufficient_scope, group_not_allowed, not_m2m_token
+	// or invalid.
+	// tokenUse is one of: id, access or unknown
+	ObserveValidation(outcome, tokenUse string)
+	// ObserveFetch is called after each jwks fetch, err is nil on success
+	ObserveFetch(duration time.Duration, err error)
+	// ObserveCacheSize is called after each successful jwks fetch with the
+	// number of keys in cache
+	ObserveCacheSize(size int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveValidation(outcome, tokenUse string)     {}
+func (noopMetrics) ObserveFetch(duration time.Duration, err error) {}
+func (noopMetrics) ObserveCacheSize(size int)                      {}
+
+func (aws *Auth) metricsHook() Metrics {
+	if aws.metrics != nil {
+		return aws.metrics
+	}
+	return noopMetrics{}
+}
+
+// fetchError mark the errors that happen while fetching the jwks
+type fetchError struct {
+	err error
+}
+
+func (e *fetchError) Error() string { return e.err.Error() }
+func (e *fetchError) Unwrap() error { return e.err }
+
+// observeValidation report the validation result to the metrics, to the
+// span of ctx, to the logger and to the hooks
+func (aws *Auth) observeValidation(ctx context.Context, token *jwt.Token, err error) {
+	outcome, tokenUse := outcome(err), tokenUse(token)
+	aws.metricsHook().ObserveValidation(outcome, tokenUse)
+	if err != nil {
+		aws.logValidationFailed(ctx, token, outcome, tokenUse, err)
+		if aws.hooks.OnValidationFailure != nil {
+			aws.hooks.OnValidationFailure(err, tokenHeader(token))
+		}
+	}
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attrOutcome.String(outcome), attrTokenUse.String(tokenUse))
+	recordError(span, err)
+}
+
+// outcome classify the result of a validation
+func outcome(err error) string {
+	if err == nil {
+		return "valid"
+	}
+	var ve *jwt.ValidationError
+	if errors.As(err, &ve) {
+		switch {
+		case ve.Errors&jwt.ValidationErrorMalformed != 0:
+			return "malformed"
+		case ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+			return "invalid_signature"
+		case ve.Errors&jwt.ValidationErrorExpired != 0:
+			return "expired"
+		case ve.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+			return "not_yet_valid"
+		}
+		return "invalid"
+	}
+	var fe *fetchError
+	switch {
+	case errors.As(err, &fe):
+		return "key_fetch_error"
+	case errors.Is(err, ErrNoKid):
+		return "no_kid"
+	case errors.Is(err, ErrKidNotFound):
+		return "unknown_kid"
+	case errors.Is(err, ErrInvalidAlg):
+		return "invalid_alg"
+	case errors.Is(err, ErrInvalidIssuer):
+		return "invalid_issuer"
+	case errors.Is(err, ErrInvalidNonce):
+		return "invalid_nonce"
+	case errors.Is(err, ErrInvalidAtHash):
+		return "invalid_at_hash"
+	case errors.Is(err, ErrAuthTooOld):
+		return "auth_too_old"
+	case errors.Is(err, ErrInvalidClientID):
+		return "invalid_client_id"
+	case errors.Is(err, ErrInsufficientScope):
+		return "insufficient_scope"
+	case errors.Is(err, ErrGroupNotAllowed):
+		return "group_not_allowed"
+	case errors.Is(err, ErrNotM2MToken):
+		return "not_m2m_token"
+	}
+	return "invalid"
+}
+
+// tokenUse return the token_use claim of the token, restricted to the known
+// values so it can safely be used as a label even when the token is not valid
+func tokenUse(token *jwt.Token) string {
+	if token != nil {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			switch claims["token_use"] {
+			case "id":
+				return "id"
+			case "access":
+				return "access"
+			}
+		}
+	}
+	return "unknown"
+}
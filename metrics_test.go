@@ -0,0 +1,71 @@
+package cognito
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type testMetrics struct {
+	outcome   string
+	tokenUse  string
+	fetches   int
+	fetchErr  error
+	cacheSize int
+}
+
+func (m *testMetrics) ObserveValidation(outcome, tokenUse string) {
+	m.outcome, m.tokenUse = outcome, tokenUse
+}
+
+func (m *testMetrics) ObserveFetch(duration time.Duration, err error) {
+	m.fetches++
+	m.fetchErr = err
+}
+
+func (m *testMetrics) ObserveCacheSize(size int) {
+	m.cacheSize = size
+}
+
+func TestAuth_ValidateToken_metrics(t *testing.T) {
+	srv, _ := newTestIssuer(t, nil, testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey))
+	claims := func(tokenUse string, exp float64) jwt.MapClaims {
+		return jwt.MapClaims{"token_use": tokenUse, "exp": exp}
+	}
+	noKid, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, claims("id", 9.56034296e+09)).SignedString(testRSAKey)
+	tests := []struct {
+		name         string
+		jwksURL      string
+		token        string
+		wantOutcome  string
+		wantTokenUse string
+		wantFetches  int
+	}{
+		{"valid id token", srv.URL + "/jwks", testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims("id", 9.56034296e+09)), "valid", "id", 1},
+		{"expired access token", srv.URL + "/jwks", testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims("access", 1)), "expired", "access", 1},
+		{"unexpected token_use", srv.URL + "/jwks", testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims("<script>", 9.56034296e+09)), "valid", "unknown", 1},
+		{"invalid signature", srv.URL + "/jwks", testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims("id", 9.56034296e+09))[:300], "invalid_signature", "id", 1},
+		{"malformed token", srv.URL + "/jwks", "xx.yy", "malformed", "unknown", 0},
+		{"no kid", srv.URL + "/jwks", noKid, "no_kid", "id", 0},
+		{"unknown kid", srv.URL + "/jwks", testSign(jwt.SigningMethodRS256, "kid-unknown", testRSAKey, claims("id", 9.56034296e+09)), "unknown_kid", "id", 1},
+		{"invalid alg", srv.URL + "/jwks", testSign(jwt.SigningMethodPS256, "kid-rsa", testRSAKey, claims("id", 9.56034296e+09)), "invalid_alg", "id", 1},
+		{"fetch error", srv.URL + "/not-found", testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims("id", 9.56034296e+09)), "key_fetch_error", "id", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &testMetrics{}
+			aws := New("", "", WithJWKSURL(tt.jwksURL), WithMetrics(metrics))
+			aws.ValidateToken(tt.token)
+			if metrics.outcome != tt.wantOutcome || metrics.tokenUse != tt.wantTokenUse {
+				t.Errorf("Metrics.ObserveValidation() = %v, %v, want %v, %v", metrics.outcome, metrics.tokenUse, tt.wantOutcome, tt.wantTokenUse)
+			}
+			if metrics.fetches != tt.wantFetches {
+				t.Errorf("Metrics.ObserveFetch() called %v times, want %v", metrics.fetches, tt.wantFetches)
+			}
+			if tt.wantOutcome == "valid" && metrics.cacheSize != 1 {
+				t.Errorf("Metrics.ObserveCacheSize() = %v, want 1", metrics.cacheSize)
+			}
+		})
+	}
+}
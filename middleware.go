@@ -0,0 +1,87 @@
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type contextKey struct{}
+
+// Middleware return an http middleware that validate the bearer token of
+// the `Authorization` header. opts are the rules of the route, for example
+// WithMaxAuthAge for a sensitive operation.
+// The claims of a valid token are available with ClaimsFromContext.
+// An invalid token is rejected with a 401 and a `WWW-Authenticate` header,
+// a token missing a scope of WithScopes or a group of WithGroups with a 403,
+// see https://www.rfc-editor.org/rfc/rfc6750#section-3 and
+// https://www.rfc-editor.org/rfc/rfc9470 for the step-up error
+func (aws *Auth) Middleware(opts ...ValidateOption) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, ok := BearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			claims, err := aws.ValidateTokenContext(r.Context(), tokenString, opts...)
+			if err != nil {
+				challenge, code := Challenge(err)
+				if challenge != "" {
+					w.Header().Set("WWW-Authenticate", challenge)
+				}
+				http.Error(w, http.StatusText(code), code)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// BearerToken return the token of the `Authorization: Bearer` header
+func BearerToken(r *http.Request) (string, bool) {
+	const prefix = "bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return strings.TrimSpace(header[len(prefix):]), true
+}
+
+// Challenge return the `WWW-Authenticate` header and the status code the
+// Middleware responds with when the validation fails with err. The header is
+// empty when the client can do nothing about it. A failed jwks fetch is a 503,
+// the token may be valid and the client should retry later
+func Challenge(err error) (string, int) {
+	var fetchErr *fetchError
+	if errors.As(err, &fetchErr) {
+		return "", http.StatusServiceUnavailable
+	}
+	var ageErr *authAgeError
+	if errors.As(err, &ageErr) {
+		return fmt.Sprintf(`Bearer error="insufficient_user_authentication", error_description="a more recent authentication is required", max_age=%d`,
+			int64(ageErr.maxAge.Seconds())), http.StatusUnauthorized
+	}
+	var scopeErr *scopeError
+	if errors.As(err, &scopeErr) {
+		return fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, strings.Join(scopeErr.scopes, " ")), http.StatusForbidden
+	}
+	if errors.Is(err, ErrGroupNotAllowed) {
+		return "", http.StatusForbidden
+	}
+	return `Bearer error="invalid_token"`, http.StatusUnauthorized
+}
+
+// ContextWithClaims return a copy of ctx holding the claims of a valid token
+func ContextWithClaims(ctx context.Context, claims map[string]interface{}) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// ClaimsFromContext return the claims stored by the Middleware
+func ClaimsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	claims, ok := ctx.Value(contextKey{}).(map[string]interface{})
+	return claims, ok
+}
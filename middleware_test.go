@@ -0,0 +1,148 @@
+package cognito_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cognito "github.com/AyWa/jwt-cognito"
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func TestWithMaxAuthAge(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	auth := s.Auth()
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"should succeed: recent authentication", s.AccessToken(cognitotest.WithClaim("auth_time", time.Now().Add(-time.Minute).Unix())), nil},
+		{"should return error: old authentication", s.AccessToken(cognitotest.WithClaim("auth_time", time.Now().Add(-time.Hour).Unix())), cognito.ErrAuthTooOld},
+		{"should return error: no auth_time", s.AccessToken(cognitotest.WithoutClaim("auth_time")), cognito.ErrAuthTooOld},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.ValidateAccessToken(tt.token, cognito.WithMaxAuthAge(5*time.Minute))
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("Auth.ValidateAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuth_Middleware(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	auth := s.Auth()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := cognito.ClaimsFromContext(r.Context())
+		if !ok {
+			t.Error("ClaimsFromContext() found no claims")
+		}
+		w.Write([]byte(claims["username"].(string)))
+	})
+	tests := []struct {
+		name          string
+		opts          []cognito.ValidateOption
+		authorization string
+		wantCode      int
+		wantBody      string
+		wantChallenge string
+	}{
+		{
+			"should call the handler with the claims",
+			nil,
+			"Bearer " + s.AccessToken(),
+			http.StatusOK,
+			cognitotest.DefaultUsername,
+			"",
+		},
+		{
+			"should accept a lower case scheme",
+			nil,
+			"bearer " + s.AccessToken(),
+			http.StatusOK,
+			cognitotest.DefaultUsername,
+			"",
+		},
+		{
+			"should reject a request without token",
+			nil,
+			"",
+			http.StatusUnauthorized,
+			"",
+			"Bearer",
+		},
+		{
+			"should reject an invalid token",
+			nil,
+			"Bearer " + s.AccessToken(cognitotest.WithExpiry(time.Now().Add(-time.Hour))),
+			http.StatusUnauthorized,
+			"",
+			`Bearer error="invalid_token"`,
+		},
+		{
+			"should accept a recent authentication",
+			[]cognito.ValidateOption{cognito.WithMaxAuthAge(5 * time.Minute)},
+			"Bearer " + s.AccessToken(),
+			http.StatusOK,
+			cognitotest.DefaultUsername,
+			"",
+		},
+		{
+			"should ask for a new authentication",
+			[]cognito.ValidateOption{cognito.WithMaxAuthAge(5 * time.Minute)},
+			"Bearer " + s.AccessToken(cognitotest.WithClaim("auth_time", time.Now().Add(-time.Hour).Unix())),
+			http.StatusUnauthorized,
+			"",
+			`Bearer error="insufficient_user_authentication", error_description="a more recent authentication is required", max_age=300`,
+		},
+		{
+			"should reject a token without the scope",
+			[]cognito.ValidateOption{cognito.WithScopes("orders/write")},
+			"Bearer " + s.AccessToken(cognitotest.WithClaim("scope", "orders/read")),
+			http.StatusForbidden,
+			"",
+			`Bearer error="insufficient_scope", scope="orders/write"`,
+		},
+		{
+			"should accept a member of the groups",
+			[]cognito.ValidateOption{cognito.WithGroups("admin", "support")},
+			"Bearer " + s.AccessToken(cognitotest.WithGroups("users", "support")),
+			http.StatusOK,
+			cognitotest.DefaultUsername,
+			"",
+		},
+		{
+			"should reject a user in none of the groups",
+			[]cognito.ValidateOption{cognito.WithGroups("admin")},
+			"Bearer " + s.AccessToken(cognitotest.WithGroups("users")),
+			http.StatusForbidden,
+			"",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/payout", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			auth.Middleware(tt.opts...)(handler).ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("Middleware() code = %v, want %v", w.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusOK && w.Body.String() != tt.wantBody {
+				t.Errorf("Middleware() body = %v, want %v", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("Middleware() WWW-Authenticate = %v, want %v", got, tt.wantChallenge)
+			}
+		})
+	}
+}
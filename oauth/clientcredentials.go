@@ -0,0 +1,99 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// ClientCredentials is a machine to machine token source: it gets access
+// tokens with the client credentials grant and keeps them until shortly
+// before they expire. It is safe for concurrent use.
+// It is also an http.RoundTripper adding the token to the requests, so it
+// can be the Transport of an http.Client
+type ClientCredentials struct {
+	// RefreshBefore can be changed before the first use, DefaultRefreshBefore by default.
+	// It is capped to the half of the lifetime of the access token
+	RefreshBefore time.Duration
+	// Base is the transport sending the requests, http.DefaultTransport by default
+	Base http.RoundTripper
+
+	config Config
+	auth   *cognito.Auth
+	scopes []string
+	mu     sync.Mutex
+	token  Token
+	issued time.Time
+}
+
+// NewClientCredentials create a token source for the confidential app
+// client of config, requesting scopes. Without scopes cognito grants all the
+// scopes allowed for the client. The tokens are validated with auth
+func NewClientCredentials(config Config, auth *cognito.Auth, scopes ...string) *ClientCredentials {
+	return &ClientCredentials{
+		RefreshBefore: DefaultRefreshBefore,
+		config:        config,
+		auth:          auth,
+		scopes:        scopes,
+	}
+}
+
+// Token return the cached token, a new one is requested when it is missing
+// or expires soon
+func (c *ClientCredentials) Token(ctx context.Context) (Token, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token.AccessToken != "" && time.Now().Before(refreshAt(c.issued, c.token.Expiry, c.RefreshBefore)) {
+		return c.token, nil
+	}
+	if c.config.ClientSecret == "" {
+		return Token{}, errors.New("client credentials need a client secret")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	token, err := c.config.exchange(ctx, form)
+	if err != nil {
+		return Token{}, err
+	}
+	access, err := c.auth.ValidateM2MTokenContext(ctx, token.AccessToken, c.config.ClientID, c.scopes)
+	if err != nil {
+		return Token{}, fmt.Errorf("invalid access token: %w", err)
+	}
+	token.Expiry = access.Exp
+	c.token = *token
+	c.issued = access.Iat
+	return c.token, nil
+}
+
+// RoundTrip send req with the access token in the `Authorization` header
+func (c *ClientCredentials) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := c.Token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	// a RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return c.base().RoundTrip(req)
+}
+
+func (c *ClientCredentials) base() http.RoundTripper {
+	if c.Base != nil {
+		return c.Base
+	}
+	return http.DefaultTransport
+}
@@ -0,0 +1,168 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	cognito "github.com/AyWa/jwt-cognito"
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func TestClientCredentials(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	endpoint := newTestTokenEndpoint(t, func(form url.Values, clientID, clientSecret string) (int, interface{}) {
+		if form.Get("grant_type") != "client_credentials" || clientID != s.ClientID || clientSecret != "s3cr3t" {
+			return http.StatusBadRequest, map[string]string{"error": "invalid_client"}
+		}
+		scope := form.Get("scope")
+		switch scope {
+		case "":
+			scope = "orders/read orders/write"
+		case "orders/admin":
+			// the scope is not allowed for the client
+			scope = "orders/read"
+		}
+		return http.StatusOK, map[string]interface{}{
+			"access_token": s.M2MToken(cognitotest.WithClaim("scope", scope), cognitotest.WithExpiry(exp)),
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		}
+	})
+	config := Config{Domain: endpoint.URL, ClientID: s.ClientID, ClientSecret: "s3cr3t"}
+
+	t.Run("should get and cache a token", func(t *testing.T) {
+		before := endpoint.count()
+		c := NewClientCredentials(config, s.Auth(), "orders/read")
+		for i := 0; i < 3; i++ {
+			got, err := c.Token(context.Background())
+			if err != nil {
+				t.Fatalf("ClientCredentials.Token() error = %v", err)
+			}
+			if got.AccessToken == "" || got.RefreshToken != "" || !got.Expiry.Equal(exp) {
+				t.Errorf("ClientCredentials.Token() = %+v", got)
+			}
+		}
+		if got := endpoint.count() - before; got != 1 {
+			t.Errorf("the token endpoint was called %v times, want 1", got)
+		}
+		if got := endpoint.requests[len(endpoint.requests)-1].Get("scope"); got != "orders/read" {
+			t.Errorf("scope = %v, want orders/read", got)
+		}
+	})
+
+	t.Run("should get a token once for concurrent callers", func(t *testing.T) {
+		before := endpoint.count()
+		c := NewClientCredentials(config, s.Auth())
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := c.Token(context.Background()); err != nil {
+					t.Errorf("ClientCredentials.Token() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if got := endpoint.count() - before; got != 1 {
+			t.Errorf("the token endpoint was called %v times, want 1", got)
+		}
+	})
+
+	t.Run("should get a new token ahead of the expiration", func(t *testing.T) {
+		before := endpoint.count()
+		c := NewClientCredentials(config, s.Auth())
+		c.token = Token{AccessToken: "xx.yy.zz", Expiry: time.Now().Add(time.Minute)}
+		c.issued = time.Now().Add(-59 * time.Minute)
+		got, err := c.Token(context.Background())
+		if err != nil || got.AccessToken == "xx.yy.zz" {
+			t.Errorf("ClientCredentials.Token() = %+v, %v", got, err)
+		}
+		if got := endpoint.count() - before; got != 1 {
+			t.Errorf("the token endpoint was called %v times, want 1", got)
+		}
+	})
+
+	t.Run("should keep a short lived token for half its lifetime", func(t *testing.T) {
+		short := newTestTokenEndpoint(t, func(form url.Values, clientID, clientSecret string) (int, interface{}) {
+			return http.StatusOK, map[string]interface{}{
+				"access_token": s.M2MToken(cognitotest.WithExpiry(time.Now().Add(5 * time.Minute))),
+				"token_type":   "Bearer",
+				"expires_in":   300,
+			}
+		})
+		c := NewClientCredentials(Config{Domain: short.URL, ClientID: s.ClientID, ClientSecret: "s3cr3t"}, s.Auth())
+		for i := 0; i < 3; i++ {
+			if _, err := c.Token(context.Background()); err != nil {
+				t.Fatalf("ClientCredentials.Token() error = %v", err)
+			}
+		}
+		if got := short.count(); got != 1 {
+			t.Errorf("the token endpoint was called %v times, want 1", got)
+		}
+	})
+
+	t.Run("should reject a token without the requested scope", func(t *testing.T) {
+		c := NewClientCredentials(config, s.Auth(), "orders/admin")
+		if _, err := c.Token(context.Background()); !errors.Is(err, cognito.ErrInsufficientScope) {
+			t.Errorf("ClientCredentials.Token() error = %v, want ErrInsufficientScope", err)
+		}
+	})
+
+	t.Run("should reject a token of an other user pool", func(t *testing.T) {
+		other := cognitotest.NewServer()
+		defer other.Close()
+		c := NewClientCredentials(config, other.Auth())
+		if _, err := c.Token(context.Background()); err == nil {
+			t.Error("ClientCredentials.Token() error = nil")
+		}
+	})
+
+	t.Run("should need a client secret", func(t *testing.T) {
+		c := NewClientCredentials(Config{Domain: endpoint.URL, ClientID: s.ClientID}, s.Auth())
+		if _, err := c.Token(context.Background()); err == nil {
+			t.Error("ClientCredentials.Token() error = nil")
+		}
+	})
+
+	t.Run("should return the token endpoint error", func(t *testing.T) {
+		c := NewClientCredentials(Config{Domain: endpoint.URL, ClientID: s.ClientID, ClientSecret: "wrong"}, s.Auth())
+		_, err := c.Token(context.Background())
+		var oauthErr *Error
+		if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_client" {
+			t.Errorf("ClientCredentials.Token() error = %v, want invalid_client", err)
+		}
+	})
+
+	t.Run("should send the token to the api", func(t *testing.T) {
+		auth := s.Auth()
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _ := cognito.BearerToken(r)
+			if _, err := auth.ValidateM2MToken(token, s.ClientID, []string{"orders/write"}); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+			}
+		}))
+		defer api.Close()
+		client := &http.Client{Transport: NewClientCredentials(config, s.Auth(), "orders/write")}
+		req, _ := http.NewRequest(http.MethodGet, api.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %v, want 200", resp.StatusCode)
+		}
+		if req.Header.Get("Authorization") != "" {
+			t.Error("the request was modified")
+		}
+	})
+}
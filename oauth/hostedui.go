@@ -0,0 +1,233 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// ErrUnknownState is returned when the state of a callback was not issued
+// by LoginURL, was already used or has expired
+var ErrUnknownState = errors.New("oauth: unknown state")
+
+// ErrTooManyStates is returned by MemoryStateStore when MaxStates logins are
+// pending, so that unfinished logins can not fill the memory
+var ErrTooManyStates = errors.New("oauth: too many pending states")
+
+// DefaultMaxStates is the number of pending logins a MemoryStateStore keeps
+const DefaultMaxStates = 10000
+
+// AuthRequest is what is kept between the redirection to the hosted UI and
+// the callback
+type AuthRequest struct {
+	// Verifier is the PKCE code verifier
+	Verifier string
+	Nonce    string
+	Created  time.Time
+}
+
+// StateStore keep the AuthRequest of each state
+type StateStore interface {
+	Save(ctx context.Context, state string, req AuthRequest) error
+	// Take return the AuthRequest of state and remove it, so a state can
+	// only be used once. ErrUnknownState is returned when there is none
+	Take(ctx context.Context, state string) (AuthRequest, error)
+}
+
+// HostedUI implements the authorization code flow with PKCE through the
+// cognito hosted UI
+// see https://docs.aws.amazon.com/cognito/latest/developerguide/authorization-endpoint.html
+type HostedUI struct {
+	Config
+	// Auth validates the tokens received on callback
+	Auth *cognito.Auth
+	// RedirectURI is the callback url, it must be allowed in the app client
+	RedirectURI string
+	// LogoutURI is the sign out url, it must be allowed in the app client
+	LogoutURI string
+	// Scopes are the requested scopes, "openid" by default
+	Scopes []string
+	// Store keep the PKCE verifiers, an in memory store by default
+	Store StateStore
+
+	defaultStore sync.Once
+}
+
+// Session is the result of a successful login, the tokens are validated
+type Session struct {
+	Token  Token
+	ID     *cognito.IDTokenPayload
+	Access *cognito.AccessTokenPayload
+}
+
+func (h *HostedUI) store() StateStore {
+	h.defaultStore.Do(func() {
+		if h.Store == nil {
+			h.Store = NewMemoryStateStore(10 * time.Minute)
+		}
+	})
+	return h.Store
+}
+
+// LoginURL generates a state, a nonce and a PKCE verifier and return the
+// hosted UI url the user should be redirected to
+func (h *HostedUI) LoginURL(ctx context.Context) (string, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	req := AuthRequest{Created: time.Now()}
+	if req.Verifier, err = randomString(); err != nil {
+		return "", err
+	}
+	if req.Nonce, err = randomString(); err != nil {
+		return "", err
+	}
+	if err := h.store().Save(ctx, state, req); err != nil {
+		return "", errors.Wrap(err, "fail to save state")
+	}
+	scopes := h.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid"}
+	}
+	challenge := sha256.Sum256([]byte(req.Verifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", h.ClientID)
+	query.Set("redirect_uri", h.RedirectURI)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", req.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	return h.endpoint("/oauth2/authorize") + "?" + query.Encode(), nil
+}
+
+// LogoutURL return the hosted UI url that sign the user out and redirect
+// to LogoutURI
+// see https://docs.aws.amazon.com/cognito/latest/developerguide/logout-endpoint.html
+func (h *HostedUI) LogoutURL() string {
+	query := url.Values{}
+	query.Set("client_id", h.ClientID)
+	query.Set("logout_uri", h.LogoutURI)
+	return h.endpoint("/logout") + "?" + query.Encode()
+}
+
+// Callback exchange the code of the callback request, see Exchange
+func (h *HostedUI) Callback(r *http.Request) (*Session, error) {
+	query := r.URL.Query()
+	if code := query.Get("error"); code != "" {
+		return nil, &Error{Code: code, Description: query.Get("error_description")}
+	}
+	return h.Exchange(r.Context(), query.Get("state"), query.Get("code"))
+}
+
+// Exchange exchange the authorization code for tokens. The id token nonce
+// and at_hash are verified, as well as the audience of the tokens
+func (h *HostedUI) Exchange(ctx context.Context, state, code string) (*Session, error) {
+	if state == "" || code == "" {
+		return nil, errors.New("oauth: state and code are required")
+	}
+	req, err := h.store().Take(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", h.RedirectURI)
+	form.Set("code_verifier", req.Verifier)
+	token, err := h.exchange(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token, is the openid scope allowed?")
+	}
+	access, id, err := validate(ctx, h.Auth, token,
+		cognito.WithNonce(req.Nonce), cognito.WithAccessTokenHash(token.AccessToken))
+	if err != nil {
+		return nil, err
+	}
+	if id.Aud != h.ClientID || access.ClientID != h.ClientID {
+		return nil, errors.New("tokens were not issued for this app client")
+	}
+	return &Session{Token: *token, ID: id, Access: access}, nil
+}
+
+// MemoryStateStore is a StateStore for a single instance application
+type MemoryStateStore struct {
+	// MaxStates can be changed before the first use, DefaultMaxStates by default
+	MaxStates int
+
+	ttl      time.Duration
+	mu       sync.Mutex
+	requests map[string]AuthRequest
+	swept    time.Time
+}
+
+// NewMemoryStateStore create a store where a state expires after ttl
+func NewMemoryStateStore(ttl time.Duration) *MemoryStateStore {
+	return &MemoryStateStore{
+		MaxStates: DefaultMaxStates,
+		ttl:       ttl,
+		requests:  map[string]AuthRequest{},
+		swept:     time.Now(),
+	}
+}
+
+// Save implements StateStore. The expired states are dropped at most once
+// per ttl, or when the store is full. ErrTooManyStates is returned when it
+// is still full
+func (s *MemoryStateStore) Save(ctx context.Context, state string, req AuthRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) >= s.MaxStates || time.Since(s.swept) > s.ttl {
+		s.sweep()
+	}
+	if len(s.requests) >= s.MaxStates {
+		return ErrTooManyStates
+	}
+	s.requests[state] = req
+	return nil
+}
+
+func (s *MemoryStateStore) sweep() {
+	for k, v := range s.requests {
+		if time.Since(v.Created) > s.ttl {
+			delete(s.requests, k)
+		}
+	}
+	s.swept = time.Now()
+}
+
+// Take implements StateStore
+func (s *MemoryStateStore) Take(ctx context.Context, state string) (AuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req, ok := s.requests[state]
+	delete(s.requests, state)
+	if !ok || time.Since(req.Created) > s.ttl {
+		return AuthRequest{}, ErrUnknownState
+	}
+	return req, nil
+}
+
+// randomString is 256 random bits, it is also a valid PKCE verifier
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "fail to generate random")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+// testHostedUI is a stand-in of the hosted UI: authorize remember the
+// challenge and the nonce of a code, the token endpoint check them
+type testHostedUI struct {
+	mu    sync.Mutex
+	codes map[string]url.Values
+	// badNonce make the token endpoint return an id token with an other nonce
+	badNonce bool
+}
+
+func (h *testHostedUI) authorize(t *testing.T, loginURL, code string) url.Values {
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.mu.Lock()
+	h.codes[code] = u.Query()
+	h.mu.Unlock()
+	return u.Query()
+}
+
+func (h *testHostedUI) token(s *cognitotest.Server) func(form url.Values, clientID, clientSecret string) (int, interface{}) {
+	return func(form url.Values, clientID, clientSecret string) (int, interface{}) {
+		h.mu.Lock()
+		params, ok := h.codes[form.Get("code")]
+		delete(h.codes, form.Get("code"))
+		h.mu.Unlock()
+		challenge := sha256.Sum256([]byte(form.Get("code_verifier")))
+		if !ok || form.Get("grant_type") != "authorization_code" || form.Get("client_id") != s.ClientID ||
+			form.Get("redirect_uri") != params.Get("redirect_uri") ||
+			base64.RawURLEncoding.EncodeToString(challenge[:]) != params.Get("code_challenge") {
+			return http.StatusBadRequest, map[string]string{"error": "invalid_grant"}
+		}
+		nonce := params.Get("nonce")
+		if h.badNonce {
+			nonce = "other"
+		}
+		accessToken := s.AccessToken()
+		return http.StatusOK, map[string]interface{}{
+			"access_token":  accessToken,
+			"id_token":      s.IDToken(cognitotest.WithClaim("nonce", nonce), cognitotest.WithAccessTokenHash(accessToken)),
+			"refresh_token": "refresh-1",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		}
+	}
+}
+
+func TestHostedUI(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	stub := &testHostedUI{codes: map[string]url.Values{}}
+	endpoint := newTestTokenEndpoint(t, stub.token(s))
+	h := &HostedUI{
+		Config:      Config{Domain: endpoint.URL, ClientID: s.ClientID},
+		Auth:        s.Auth(),
+		RedirectURI: "https://app.example.com/callback",
+		LogoutURI:   "https://app.example.com/",
+		Scopes:      []string{"openid", "email"},
+	}
+	ctx := context.Background()
+
+	t.Run("should login", func(t *testing.T) {
+		loginURL, err := h.LoginURL(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(loginURL, endpoint.URL+"/oauth2/authorize?") {
+			t.Errorf("HostedUI.LoginURL() = %v", loginURL)
+		}
+		params := stub.authorize(t, loginURL, "code-1")
+		if params.Get("code_challenge_method") != "S256" || params.Get("scope") != "openid email" || params.Get("response_type") != "code" {
+			t.Errorf("HostedUI.LoginURL() params = %v", params)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/callback?code=code-1&state="+url.QueryEscape(params.Get("state")), nil)
+		session, err := h.Callback(r)
+		if err != nil {
+			t.Fatalf("HostedUI.Callback() error = %v", err)
+		}
+		if session.ID.Nonce != params.Get("nonce") || session.Access.Username != cognitotest.DefaultUsername || session.Token.RefreshToken != "refresh-1" {
+			t.Errorf("HostedUI.Callback() = %+v", session)
+		}
+
+		// the state can only be used once
+		if _, err := h.Exchange(ctx, params.Get("state"), "code-1"); !errors.Is(err, ErrUnknownState) {
+			t.Errorf("HostedUI.Exchange() error = %v, want ErrUnknownState", err)
+		}
+	})
+
+	t.Run("should reject an unknown state", func(t *testing.T) {
+		loginURL, _ := h.LoginURL(ctx)
+		stub.authorize(t, loginURL, "code-2")
+		if _, err := h.Exchange(ctx, "forged", "code-2"); !errors.Is(err, ErrUnknownState) {
+			t.Errorf("HostedUI.Exchange() error = %v, want ErrUnknownState", err)
+		}
+	})
+
+	t.Run("should reject an id token with an other nonce", func(t *testing.T) {
+		stub.badNonce = true
+		defer func() { stub.badNonce = false }()
+		loginURL, _ := h.LoginURL(ctx)
+		params := stub.authorize(t, loginURL, "code-3")
+		if _, err := h.Exchange(ctx, params.Get("state"), "code-3"); err == nil || !strings.Contains(err.Error(), "nonce") {
+			t.Errorf("HostedUI.Exchange() error = %v, want nonce error", err)
+		}
+	})
+
+	t.Run("should return the hosted UI error", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/callback?error=access_denied&error_description=User+cancelled", nil)
+		_, err := h.Callback(r)
+		var oauthErr *Error
+		if !errors.As(err, &oauthErr) || oauthErr.Code != "access_denied" {
+			t.Errorf("HostedUI.Callback() error = %v, want access_denied", err)
+		}
+	})
+
+	t.Run("should build the logout url", func(t *testing.T) {
+		want := endpoint.URL + "/logout?client_id=" + s.ClientID + "&logout_uri=https%3A%2F%2Fapp.example.com%2F"
+		if got := h.LogoutURL(); got != want {
+			t.Errorf("HostedUI.LogoutURL() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestMemoryStateStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should take a state once", func(t *testing.T) {
+		s := NewMemoryStateStore(time.Minute)
+		if err := s.Save(ctx, "state-1", AuthRequest{Verifier: "v", Created: time.Now()}); err != nil {
+			t.Fatalf("MemoryStateStore.Save() error = %v", err)
+		}
+		if got, err := s.Take(ctx, "state-1"); err != nil || got.Verifier != "v" {
+			t.Errorf("MemoryStateStore.Take() = %+v, %v", got, err)
+		}
+		if _, err := s.Take(ctx, "state-1"); !errors.Is(err, ErrUnknownState) {
+			t.Errorf("MemoryStateStore.Take() error = %v, want ErrUnknownState", err)
+		}
+	})
+
+	t.Run("should reject an expired state", func(t *testing.T) {
+		s := NewMemoryStateStore(time.Minute)
+		s.Save(ctx, "state-1", AuthRequest{Created: time.Now().Add(-2 * time.Minute)})
+		if _, err := s.Take(ctx, "state-1"); !errors.Is(err, ErrUnknownState) {
+			t.Errorf("MemoryStateStore.Take() error = %v, want ErrUnknownState", err)
+		}
+	})
+
+	t.Run("should reject a state when full", func(t *testing.T) {
+		s := NewMemoryStateStore(time.Minute)
+		s.MaxStates = 2
+		for _, state := range []string{"state-1", "state-2"} {
+			if err := s.Save(ctx, state, AuthRequest{Created: time.Now()}); err != nil {
+				t.Fatalf("MemoryStateStore.Save() error = %v", err)
+			}
+		}
+		if err := s.Save(ctx, "state-3", AuthRequest{Created: time.Now()}); !errors.Is(err, ErrTooManyStates) {
+			t.Errorf("MemoryStateStore.Save() error = %v, want ErrTooManyStates", err)
+		}
+	})
+
+	t.Run("should drop the expired states when full", func(t *testing.T) {
+		s := NewMemoryStateStore(time.Minute)
+		s.MaxStates = 2
+		s.Save(ctx, "state-1", AuthRequest{Created: time.Now().Add(-2 * time.Minute)})
+		s.Save(ctx, "state-2", AuthRequest{Created: time.Now()})
+		if err := s.Save(ctx, "state-3", AuthRequest{Created: time.Now()}); err != nil {
+			t.Errorf("MemoryStateStore.Save() error = %v", err)
+		}
+		if len(s.requests) != 2 {
+			t.Errorf("MemoryStateStore has %v states, want 2", len(s.requests))
+		}
+	})
+
+	t.Run("should drop the expired states once per ttl", func(t *testing.T) {
+		s := NewMemoryStateStore(time.Minute)
+		s.Save(ctx, "state-1", AuthRequest{Created: time.Now().Add(-2 * time.Minute)})
+		s.Save(ctx, "state-2", AuthRequest{Created: time.Now()})
+		if len(s.requests) != 2 {
+			t.Errorf("MemoryStateStore has %v states before the sweep, want 2", len(s.requests))
+		}
+		s.swept = time.Now().Add(-2 * time.Minute)
+		s.Save(ctx, "state-3", AuthRequest{Created: time.Now()})
+		if _, ok := s.requests["state-1"]; ok || len(s.requests) != 2 {
+			t.Errorf("MemoryStateStore states = %v, want state-1 dropped", s.requests)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+// Package oauth implements the client side of the cognito user pool OAuth 2.0
+// endpoints: refresh of the tokens, hosted UI authorization code flow and
+// client credentials.
+// The tokens received from cognito are always validated with a cognito.Auth
+// see https://docs.aws.amazon.com/cognito/latest/developerguide/cognito-userpools-server-contract-reference.html
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config describe a user pool app client
+type Config struct {
+	// Domain is the base url of the user pool domain, for example
+	// https://myapp.auth.us-east-1.amazoncognito.com
+	Domain   string
+	ClientID string
+	// ClientSecret is empty for a public app client
+	ClientSecret string
+	// HTTPClient is used to call the token endpoint, http.DefaultClient by default
+	HTTPClient *http.Client
+}
+
+// Token is the token set returned by the token endpoint
+type Token struct {
+	AccessToken string
+	// IDToken is only returned when the openid scope is granted
+	IDToken string
+	// RefreshToken is not returned by the client credentials grant
+	RefreshToken string
+	TokenType    string
+	// Expiry is the expiration of the access token
+	Expiry time.Time
+}
+
+// refreshAt return when a token issued at issued and expiring at expiry must
+// be renewed: before ahead of its expiration, but not before the half of its
+// lifetime, otherwise a token living less than before would be renewed on
+// every call. The lifetime is unknown when issued is zero
+func refreshAt(issued, expiry time.Time, before time.Duration) time.Time {
+	if !issued.IsZero() {
+		if half := expiry.Sub(issued) / 2; half < before {
+			before = half
+		}
+	}
+	return expiry.Add(-before)
+}
+
+// Error is an error response of the token endpoint
+// see https://www.rfc-editor.org/rfc/rfc6749#section-5.2
+type Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth: %s (status %d)", e.Code, e.StatusCode)
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (c *Config) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Config) endpoint(path string) string {
+	return strings.TrimSuffix(c.Domain, "/") + path
+}
+
+// exchange call the token endpoint with the grant of form
+func (c *Config) exchange(ctx context.Context, form url.Values) (*Token, error) {
+	if c.ClientSecret == "" {
+		form.Set("client_id", c.ClientID)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint("/oauth2/token"), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to create token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	resp, err := c.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to call token endpoint")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		e := &Error{StatusCode: resp.StatusCode}
+		if err := json.NewDecoder(resp.Body).Decode(e); err != nil || e.Code == "" {
+			e.Code = "unexpected_status"
+		}
+		return nil, e
+	}
+	body := &tokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal token response")
+	}
+	if body.AccessToken == "" {
+		return nil, errors.New("token response has no access_token")
+	}
+	token := &Token{
+		AccessToken:  body.AccessToken,
+		IDToken:      body.IDToken,
+		RefreshToken: body.RefreshToken,
+		TokenType:    body.TokenType,
+	}
+	if body.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
@@ -0,0 +1,67 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+// testTokenEndpoint is a stand-in of the cognito token endpoint
+type testTokenEndpoint struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []url.Values
+	// respond is called for each request, with the form and the client id
+	// and secret of the basic auth
+	respond func(form url.Values, clientID, clientSecret string) (int, interface{})
+}
+
+func newTestTokenEndpoint(t *testing.T, respond func(form url.Values, clientID, clientSecret string) (int, interface{})) *testTokenEndpoint {
+	e := &testTokenEndpoint{respond: respond}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/oauth2/token" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+		e.mu.Lock()
+		e.requests = append(e.requests, r.PostForm)
+		e.mu.Unlock()
+		clientID, clientSecret, _ := r.BasicAuth()
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+		code, body := e.respond(r.PostForm, clientID, clientSecret)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *testTokenEndpoint) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.requests)
+}
+
+// tokens is the response of the token endpoint with tokens minted by s
+func tokens(s *cognitotest.Server, refreshToken string, opts ...cognitotest.TokenOption) map[string]interface{} {
+	body := map[string]interface{}{
+		"access_token": s.AccessToken(opts...),
+		"id_token":     s.IDToken(opts...),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	}
+	if refreshToken != "" {
+		body["refresh_token"] = refreshToken
+	}
+	return body
+}
@@ -0,0 +1,105 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// DefaultRefreshBefore is how long before the access token expiration the
+// TokenManager refresh it
+const DefaultRefreshBefore = 5 * time.Minute
+
+// TokenManager hold a token set and renew it with the refresh token before
+// the access token expires. It is safe for concurrent use, only one refresh
+// happens at a time
+type TokenManager struct {
+	// RefreshBefore can be changed before the first use, DefaultRefreshBefore by default.
+	// It is capped to the half of the lifetime of the access token
+	RefreshBefore time.Duration
+
+	config Config
+	auth   *cognito.Auth
+	mu     sync.Mutex
+	token  Token
+	// issued is the iat of the access token, zero for the token given to NewTokenManager
+	issued time.Time
+}
+
+// NewTokenManager create a manager for token, that must at least have a
+// refresh token. The tokens received on refresh are validated with auth
+func NewTokenManager(config Config, auth *cognito.Auth, token Token) *TokenManager {
+	return &TokenManager{
+		RefreshBefore: DefaultRefreshBefore,
+		config:        config,
+		auth:          auth,
+		token:         token,
+	}
+}
+
+// Token return the current token set, it is refreshed first when the access
+// token is missing or expires soon
+func (m *TokenManager) Token(ctx context.Context) (Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token.AccessToken != "" && time.Now().Before(refreshAt(m.issued, m.token.Expiry, m.RefreshBefore)) {
+		return m.token, nil
+	}
+	return m.refresh(ctx)
+}
+
+// Refresh renew the token set now
+func (m *TokenManager) Refresh(ctx context.Context) (Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.refresh(ctx)
+}
+
+func (m *TokenManager) refresh(ctx context.Context) (Token, error) {
+	if m.token.RefreshToken == "" {
+		return Token{}, errors.New("no refresh token")
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", m.token.RefreshToken)
+	token, err := m.config.exchange(ctx, form)
+	if err != nil {
+		return Token{}, err
+	}
+	access, _, err := validate(ctx, m.auth, token)
+	if err != nil {
+		return Token{}, err
+	}
+	// cognito only return a new refresh token when the rotation is enabled
+	if token.RefreshToken == "" {
+		token.RefreshToken = m.token.RefreshToken
+	}
+	m.token = *token
+	m.issued = access.Iat
+	return m.token, nil
+}
+
+// validate check the tokens received from the token endpoint, the expiry
+// is taken from the access token itself. idOpts are the extra checks of the
+// id token, if any
+func validate(ctx context.Context, auth *cognito.Auth, token *Token, idOpts ...cognito.ValidateOption) (*cognito.AccessTokenPayload, *cognito.IDTokenPayload, error) {
+	access, err := auth.ValidateAccessTokenContext(ctx, token.AccessToken)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid access token: %w", err)
+	}
+	token.Expiry = access.Exp
+	if token.IDToken == "" {
+		return access, nil, nil
+	}
+	id, err := auth.ValidateIDTokenContext(ctx, token.IDToken, idOpts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid id token: %w", err)
+	}
+	return access, id, nil
+}
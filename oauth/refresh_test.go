@@ -0,0 +1,129 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func TestTokenManager_Token(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	endpoint := newTestTokenEndpoint(t, func(form url.Values, clientID, clientSecret string) (int, interface{}) {
+		if form.Get("grant_type") != "refresh_token" || clientID != s.ClientID || clientSecret != "s3cr3t/+" {
+			return http.StatusBadRequest, map[string]string{"error": "invalid_request"}
+		}
+		if form.Get("refresh_token") != "refresh-1" {
+			return http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "Refresh Token has expired"}
+		}
+		return http.StatusOK, tokens(s, "", cognitotest.WithExpiry(exp))
+	})
+	config := Config{Domain: endpoint.URL, ClientID: s.ClientID, ClientSecret: "s3cr3t/+"}
+
+	t.Run("should refresh when there is only a refresh token", func(t *testing.T) {
+		m := NewTokenManager(config, s.Auth(), Token{RefreshToken: "refresh-1"})
+		got, err := m.Token(context.Background())
+		if err != nil {
+			t.Fatalf("TokenManager.Token() error = %v", err)
+		}
+		if got.AccessToken == "" || got.IDToken == "" || got.RefreshToken != "refresh-1" || !got.Expiry.Equal(exp) {
+			t.Errorf("TokenManager.Token() = %+v", got)
+		}
+	})
+
+	t.Run("should not refresh a fresh token", func(t *testing.T) {
+		before := endpoint.count()
+		m := NewTokenManager(config, s.Auth(), Token{AccessToken: "xx.yy.zz", RefreshToken: "refresh-1", Expiry: time.Now().Add(time.Hour)})
+		got, err := m.Token(context.Background())
+		if err != nil || got.AccessToken != "xx.yy.zz" {
+			t.Errorf("TokenManager.Token() = %+v, %v", got, err)
+		}
+		if endpoint.count() != before {
+			t.Errorf("the token endpoint was called")
+		}
+	})
+
+	t.Run("should refresh ahead of the expiration", func(t *testing.T) {
+		m := NewTokenManager(config, s.Auth(), Token{AccessToken: "xx.yy.zz", RefreshToken: "refresh-1", Expiry: time.Now().Add(time.Minute)})
+		got, err := m.Token(context.Background())
+		if err != nil || got.AccessToken == "xx.yy.zz" {
+			t.Errorf("TokenManager.Token() = %+v, %v", got, err)
+		}
+	})
+
+	t.Run("should keep a short lived token for half its lifetime", func(t *testing.T) {
+		short := newTestTokenEndpoint(t, func(form url.Values, clientID, clientSecret string) (int, interface{}) {
+			return http.StatusOK, tokens(s, "", cognitotest.WithExpiry(time.Now().Add(5*time.Minute)))
+		})
+		m := NewTokenManager(Config{Domain: short.URL, ClientID: s.ClientID}, s.Auth(), Token{RefreshToken: "refresh-1"})
+		for i := 0; i < 3; i++ {
+			if _, err := m.Token(context.Background()); err != nil {
+				t.Fatalf("TokenManager.Token() error = %v", err)
+			}
+		}
+		if got := short.count(); got != 1 {
+			t.Errorf("the token endpoint was called %v times, want 1", got)
+		}
+	})
+
+	t.Run("should refresh once for concurrent callers", func(t *testing.T) {
+		before := endpoint.count()
+		m := NewTokenManager(config, s.Auth(), Token{RefreshToken: "refresh-1"})
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := m.Token(context.Background()); err != nil {
+					t.Errorf("TokenManager.Token() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		if got := endpoint.count() - before; got != 1 {
+			t.Errorf("the token endpoint was called %v times, want 1", got)
+		}
+	})
+
+	t.Run("should return the token endpoint error", func(t *testing.T) {
+		m := NewTokenManager(config, s.Auth(), Token{RefreshToken: "refresh-expired"})
+		_, err := m.Token(context.Background())
+		var oauthErr *Error
+		if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" || oauthErr.StatusCode != http.StatusBadRequest {
+			t.Errorf("TokenManager.Token() error = %v, want invalid_grant", err)
+		}
+	})
+
+	t.Run("should fail without refresh token", func(t *testing.T) {
+		m := NewTokenManager(config, s.Auth(), Token{})
+		if _, err := m.Token(context.Background()); err == nil {
+			t.Errorf("TokenManager.Token() error = nil")
+		}
+	})
+}
+
+func TestTokenManager_Refresh_invalidToken(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	other := cognitotest.NewServer()
+	defer other.Close()
+	// the tokens are signed by an other pool
+	endpoint := newTestTokenEndpoint(t, func(form url.Values, clientID, clientSecret string) (int, interface{}) {
+		return http.StatusOK, tokens(other, "")
+	})
+	config := Config{Domain: endpoint.URL, ClientID: s.ClientID}
+	m := NewTokenManager(config, s.Auth(), Token{RefreshToken: "refresh-1"})
+	if _, err := m.Refresh(context.Background()); err == nil {
+		t.Errorf("TokenManager.Refresh() error = nil, want invalid access token")
+	}
+	if got := endpoint.requests[0].Get("client_id"); got != s.ClientID {
+		t.Errorf("client_id = %v, want %v for a public client", got, s.ClientID)
+	}
+}
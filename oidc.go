@@ -0,0 +1,69 @@
+package cognito
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type discoveryDocument struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// NewOIDC is the constructor for a generic OpenID Connect issuer (Keycloak, Okta...).
+// It reads the issuer discovery document in order to find the jwks url and
+// the supported algorithms. Unlike New, the `iss` claim of the token
+// must match the issuer.
+// For cognito prefer New, that does not need the discovery document.
+// With WithJWKS the discovery document is not fetched, so it can be used
+// offline: the keys are the given ones and every algorithm of the keys is
+// accepted
+func NewOIDC(ctx context.Context, issuerURL string, opts ...Option) (*Auth, error) {
+	aws := New("", "", opts...)
+	if aws.jwks != nil {
+		aws.issuer = issuerURL
+		return aws, nil
+	}
+	doc, err := aws.fetchDiscovery(ctx, issuerURL)
+	if err != nil {
+		return nil, err
+	}
+	// see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderConfigurationValidation
+	if doc.Issuer != issuerURL {
+		return nil, errors.Errorf("discovery issuer %q does not match %q", doc.Issuer, issuerURL)
+	}
+	if doc.JWKSURI == "" {
+		return nil, errors.New("discovery document has no jwks_uri")
+	}
+	aws.issuer = doc.Issuer
+	aws.jwksURL = doc.JWKSURI
+	aws.algorithms = doc.IDTokenSigningAlgValuesSupported
+	return aws, nil
+}
+
+func (aws *Auth) fetchDiscovery(ctx context.Context, issuerURL string) (*discoveryDocument, error) {
+	url := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to create discovery request")
+	}
+	resp, err := aws.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "fail to fetch discovery document")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fail to fetch discovery document: unexpected status %d", resp.StatusCode)
+	}
+	doc := &discoveryDocument{}
+	if err := json.NewDecoder(resp.Body).Decode(doc); err != nil {
+		return nil, errors.Wrap(err, "fail to unmarshal discovery document")
+	}
+	return doc, nil
+}
@@ -0,0 +1,111 @@
+package cognito
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// newTestIssuer start a fake OIDC issuer serving a discovery document and a
+// jwks at /jwks. It also return the number of jwks fetches
+func newTestIssuer(t testing.TB, algorithms []string, keys ...*awsWellKnowKey) (*httptest.Server, *int64) {
+	var fetches int64
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{
+			Issuer:                           srv.URL,
+			JWKSURI:                          srv.URL + "/jwks",
+			IDTokenSigningAlgValuesSupported: algorithms,
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&fetches, 1)
+		json.NewEncoder(w).Encode(awsWellKnowKeys{Keys: keys})
+	})
+	return srv, &fetches
+}
+
+func TestNewOIDC(t *testing.T) {
+	srv, _ := newTestIssuer(t, []string{"RS256"})
+	tests := []struct {
+		name      string
+		issuerURL string
+		wantErr   bool
+	}{
+		{"should succeed: valid discovery document", srv.URL, false},
+		{"should return error: issuer does not match", srv.URL + "/", true},
+		{"should return error: no discovery document", srv.URL + "/realms/unknown", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewOIDC(context.Background(), tt.issuerURL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewOIDC() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.keysURL() != srv.URL+"/jwks" {
+				t.Errorf("NewOIDC() jwks url = %v, want %v", got.keysURL(), srv.URL+"/jwks")
+			}
+		})
+	}
+}
+
+func TestNewOIDC_jwks(t *testing.T) {
+	jwks, _ := json.Marshal(awsWellKnowKeys{Keys: []*awsWellKnowKey{testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey)}})
+	// the issuer can not be reached, the discovery document must not be fetched
+	issuer := "http://127.0.0.1:0/realms/main"
+	aws, err := NewOIDC(context.Background(), issuer, WithJWKS(jwks))
+	if err != nil {
+		t.Fatalf("NewOIDC() error = %v", err)
+	}
+	claims := func(iss string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "iss": iss, "exp": 9.56034296e+09}
+	}
+	if _, err := aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims(issuer))); err != nil {
+		t.Errorf("Auth.ValidateToken() error = %v", err)
+	}
+	if _, err := aws.ValidateToken(testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims("https://txt.com"))); !errors.Is(err, ErrInvalidIssuer) {
+		t.Errorf("Auth.ValidateToken() error = %v, want ErrInvalidIssuer", err)
+	}
+}
+
+func TestAuth_ValidateToken_OIDC(t *testing.T) {
+	srv, _ := newTestIssuer(t, []string{"RS256", "ES256"},
+		testJWK("kid-rsa", "", &testRSAKey.PublicKey),
+		testJWK("kid-ec", "ES256", &testECKey.PublicKey),
+	)
+	aws, err := NewOIDC(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(iss string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "3a9f2f3a-e659-41da-b116-0902d1f7d4ea", "iss": iss, "exp": 9.56034296e+09}
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"should succeed: RS256 token", testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims(srv.URL)), false},
+		{"should succeed: ES256 token", testSign(jwt.SigningMethodES256, "kid-ec", testECKey, claims(srv.URL)), false},
+		{"should return error: iss does not match", testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, claims("https://txt.com")), true},
+		{"should return error: alg not supported by the issuer", testSign(jwt.SigningMethodPS256, "kid-rsa", testRSAKey, claims(srv.URL)), true},
+		{"should return error: unknown kid", testSign(jwt.SigningMethodRS256, "kid-unknown", testRSAKey, claims(srv.URL)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := aws.ValidateToken(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Auth.ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package cognito
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configure an optional setting of Auth, see New and NewOIDC
+type Option func(*Auth)
+
+// WithHTTPClient set the http client used to fetch the jwks and the
+// discovery document. By default http.DefaultClient is used
+func WithHTTPClient(client *http.Client) Option {
+	return func(aws *Auth) {
+		aws.httpClient = client
+	}
+}
+
+// WithJWKSURL override the url used to fetch the public keys.
+// It is mostly useful for tests or for a proxy in front of cognito
+func WithJWKSURL(url string) Option {
+	return func(aws *Auth) {
+		aws.jwksURL = url
+	}
+}
+
+// WithJWKS use a static jwks document instead of fetching it, for example
+// to validate tokens offline. The keys are still parsed lazily
+func WithJWKS(jwks []byte) Option {
+	return func(aws *Auth) {
+		aws.jwks = jwks
+	}
+}
+
+// WithALBKeysURL override the base url of the public keys of the ALB, a key
+// is fetched at <url>/<kid>. It is only used by NewALB
+func WithALBKeysURL(url string) Option {
+	return func(aws *Auth) {
+		aws.albKeysURL = url
+	}
+}
+
+// WithMetrics set the hook receiving the validation and jwks fetch measures
+func WithMetrics(metrics Metrics) Option {
+	return func(aws *Auth) {
+		aws.metrics = metrics
+	}
+}
+
+// WithTracerProvider enable the OpenTelemetry spans around the validation
+// and the jwks fetch. By default no span is created
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(aws *Auth) {
+		aws.tracerProvider = tp
+	}
+}
+
+// WithLogger enable the structured logs: keys loaded, kid added or removed,
+// fetch error and validation failed. By default nothing is logged
+func WithLogger(logger *slog.Logger) Option {
+	return func(aws *Auth) {
+		aws.logger = logger
+	}
+}
+
+// WithHooks set the callbacks called on key rotation and validation failures
+func WithHooks(hooks Hooks) Option {
+	return func(aws *Auth) {
+		aws.hooks = hooks
+	}
+}
+
+// WithMinRSAKeySize set the minimum size in bits of the RSA keys, the
+// smaller keys of the jwks are rejected when it is loaded.
+// By default it is DefaultMinRSAKeySize
+func WithMinRSAKeySize(bits int) Option {
+	return func(aws *Auth) {
+		aws.minRSAKeySize = bits
+	}
+}
+
+// WithBatchConcurrency set the number of tokens of a batch verified in
+// parallel, see ValidateBatch. By default it is GOMAXPROCS
+func WithBatchConcurrency(n int) Option {
+	return func(aws *Auth) {
+		aws.batchConcurrency = n
+	}
+}
+
+// ValidateOption add a check to a single validation, it is only called
+// once the signature and the expiration are verified
+type ValidateOption func(token TokenInfo, claims map[string]interface{}) error
+
+// TokenInfo describes the token given to a ValidateOption
+type TokenInfo struct {
+	// Alg is the signing algorithm of the token, for example RS256
+	Alg string
+	// Header is the decoded header of the token
+	Header map[string]interface{}
+}
+
+// WithNonce check that the nonce claim of an id token is the nonce sent in
+// the authorization request
+// see https://openid.net/specs/openid-connect-core-1_0.html#NonceNotes
+func WithNonce(nonce string) ValidateOption {
+	return func(token TokenInfo, claims map[string]interface{}) error {
+		got, _ := claims["nonce"].(string)
+		if nonce == "" || subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+			return ErrInvalidNonce
+		}
+		return nil
+	}
+}
+
+// WithAccessTokenHash check that the at_hash claim of an id token match the
+// access token issued with it. The hash function comes from the id token alg
+// see https://openid.net/specs/openid-connect-core-1_0.html#CodeIDToken
+func WithAccessTokenHash(accessToken string) ValidateOption {
+	return func(token TokenInfo, claims map[string]interface{}) error {
+		got, _ := claims["at_hash"].(string)
+		want, err := tokenHash(token.Alg, accessToken)
+		if err != nil {
+			return err
+		}
+		if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			return ErrInvalidAtHash
+		}
+		return nil
+	}
+}
+
+// tokenHash is the base64url encoding of the left half of the hash of the
+// token, the hash being the one of the alg (SHA-256 for RS256...)
+func tokenHash(alg, token string) (string, error) {
+	var h hash.Hash
+	switch {
+	case strings.HasSuffix(alg, "256"):
+		h = sha256.New()
+	case strings.HasSuffix(alg, "384"):
+		h = sha512.New384()
+	case strings.HasSuffix(alg, "512"):
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("%w: no hash for alg %s", ErrInvalidAtHash, alg)
+	}
+	h.Write([]byte(token))
+	sum := h.Sum(nil)
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+// WithMaxAuthAge reject the token when the user authenticated more than
+// maxAge ago, according to the auth_time claim. It is meant for sensitive
+// operations that need a recent authentication (step-up auth).
+// The error is ErrAuthTooOld
+func WithMaxAuthAge(maxAge time.Duration) ValidateOption {
+	return func(token TokenInfo, claims map[string]interface{}) error {
+		authTime, ok := claims["auth_time"].(float64)
+		if !ok || jwt.TimeFunc().Sub(time.Unix(int64(authTime), 0)) > maxAge {
+			return &authAgeError{maxAge: maxAge}
+		}
+		return nil
+	}
+}
+
+// authAgeError keep the max age so the middleware can send it to the client
+type authAgeError struct {
+	maxAge time.Duration
+}
+
+func (e *authAgeError) Error() string { return ErrAuthTooOld.Error() }
+func (e *authAgeError) Unwrap() error { return ErrAuthTooOld }
+
+// WithClientID reject the token unless its client_id is one of clientIDs.
+// The error is ErrInvalidClientID
+func WithClientID(clientIDs ...string) ValidateOption {
+	return func(token TokenInfo, claims map[string]interface{}) error {
+		clientID, _ := claims["client_id"].(string)
+		if clientID == "" || !containsString(clientIDs, clientID) {
+			return ErrInvalidClientID
+		}
+		return nil
+	}
+}
+
+// WithScopes reject the token unless its scope claim contains all the
+// scopes. The error is ErrInsufficientScope
+func WithScopes(scopes ...string) ValidateOption {
+	return func(token TokenInfo, claims map[string]interface{}) error {
+		scope, _ := claims["scope"].(string)
+		granted := strings.Fields(scope)
+		for _, s := range scopes {
+			if !containsString(granted, s) {
+				return &scopeError{scopes: scopes}
+			}
+		}
+		return nil
+	}
+}
+
+// WithGroups reject the token unless the user is in at least one of the
+// groups, according to the cognito:groups claim. The error is ErrGroupNotAllowed
+func WithGroups(groups ...string) ValidateOption {
+	return func(token TokenInfo, claims map[string]interface{}) error {
+		member, _ := claims["cognito:groups"].([]interface{})
+		for _, g := range member {
+			if s, ok := g.(string); ok && containsString(groups, s) {
+				return nil
+			}
+		}
+		return ErrGroupNotAllowed
+	}
+}
+
+// scopeError keep the required scopes so the middleware can send them to the client
+type scopeError struct {
+	scopes []string
+}
+
+func (e *scopeError) Error() string {
+	return fmt.Sprintf("%s: %s is required", ErrInsufficientScope, strings.Join(e.scopes, " "))
+}
+func (e *scopeError) Unwrap() error { return ErrInsufficientScope }
@@ -0,0 +1,262 @@
+// Package policy evaluates authorization rules written in CEL against the
+// claims of a validated token and the attributes of the request, instead of
+// nested ifs over the claims.
+//
+// An expression has two variables: claims, the claims of the token, and
+// request, with the method, path, host, headers, query and params (path
+// wildcards) of the request. It must return a bool, for example
+//
+//	"admin" in claims["cognito:groups"] ||
+//	  ("orders/write" in claims.scope.split(" ") && claims["custom:tenant"] == request.params.tenant)
+//
+// The expressions are compiled once, when they are loaded, so a typo is
+// reported at startup and not on the first request.
+// see https://github.com/google/cel-spec/blob/master/doc/langdef.md
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// Policy is a compiled expression
+type Policy struct {
+	Name       string
+	Expression string
+	// Logger reports the evaluation errors of the Middleware, slog.Default() by default
+	Logger *slog.Logger
+
+	program cel.Program
+}
+
+// Request are the attributes of the request available to the expression
+type Request struct {
+	Method string
+	Path   string
+	Host   string
+	// Headers and Query only have the first value, the header names are lower case
+	Headers map[string]string
+	Query   map[string]string
+	// Params are the path parameters of the route
+	Params map[string]string
+}
+
+// NewRequest return the attributes of r. params are the names of the path
+// wildcards of the route pattern, see http.Request.PathValue
+func NewRequest(r *http.Request, params ...string) Request {
+	req := Request{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Host:    r.Host,
+		Headers: make(map[string]string, len(r.Header)),
+		Query:   map[string]string{},
+		Params:  make(map[string]string, len(params)),
+	}
+	for name, values := range r.Header {
+		req.Headers[strings.ToLower(name)] = values[0]
+	}
+	for name, values := range r.URL.Query() {
+		req.Query[name] = values[0]
+	}
+	for _, name := range params {
+		req.Params[name] = r.PathValue(name)
+	}
+	return req
+}
+
+func (r Request) value() map[string]interface{} {
+	return map[string]interface{}{
+		"method":  r.Method,
+		"path":    r.Path,
+		"host":    r.Host,
+		"headers": nonNil(r.Headers),
+		"query":   nonNil(r.Query),
+		"params":  nonNil(r.Params),
+	}
+}
+
+func nonNil(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+// env is the environment of all the expressions
+var env = func() *cel.Env {
+	env, err := cel.NewEnv(
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		ext.Strings(),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("policy: invalid cel environment: %v", err))
+	}
+	return env
+}()
+
+// Compile parse and check expression, the error describe the problems of
+// the expression with their position
+func Compile(name, expression string) (*Policy, error) {
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("policy %s: %w", name, issues.Err())
+	}
+	// a dyn expression, like claims.admin, is only checked on evaluation
+	if t := ast.OutputType(); t != cel.BoolType && t != cel.DynType {
+		return nil, fmt.Errorf("policy %s: must return a bool, not %s", name, ast.OutputType())
+	}
+	program, err := env.Program(ast, cel.EvalOptions(cel.OptOptimize), cel.InterruptCheckFrequency(100))
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", name, err)
+	}
+	return &Policy{Name: name, Expression: expression, program: program}, nil
+}
+
+// Eval return whether the claims and the request satisfy the policy. An
+// error, for example a missing claim, means the policy is not satisfied
+func (p *Policy) Eval(ctx context.Context, claims map[string]interface{}, req Request) (bool, error) {
+	if claims == nil {
+		claims = map[string]interface{}{}
+	}
+	out, _, err := p.program.ContextEval(ctx, map[string]interface{}{
+		"claims":  claims,
+		"request": req.value(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("policy %s: %w", p.Name, err)
+	}
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("policy %s: returned %v instead of a bool", p.Name, out.Type())
+	}
+	return allowed, nil
+}
+
+// Middleware return an http middleware that reject with a 403 the requests
+// that do not satisfy the policy. It must be used after the cognito
+// Middleware, that stores the claims. params are the names of the path
+// wildcards of the route, available as request.params.
+// The evaluation errors are also rejected with a 403, and logged since they
+// can be a mistake in the expression
+func (p *Policy) Middleware(params ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := cognito.ClaimsFromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			allowed, err := p.Eval(r.Context(), claims, NewRequest(r, params...))
+			if err != nil {
+				p.logger().LogAttrs(r.Context(), slog.LevelWarn, "policy: evaluation failed",
+					slog.String("policy", p.Name),
+					slog.String("error", err.Error()),
+				)
+			}
+			if !allowed {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (p *Policy) logger() *slog.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return slog.Default()
+}
+
+// Set is a set of policies by name, loaded from a configuration
+type Set struct {
+	policies map[string]*Policy
+}
+
+// Load read a JSON configuration mapping the policy names to their
+// expression, and compile all of them:
+//
+//	{"policies": {"orders-write": "\"orders/write\" in claims.scope.split(\" \")"}}
+//
+// The error lists the problems of all the invalid policies
+func Load(r io.Reader) (*Set, error) {
+	config := struct {
+		Policies map[string]string `json:"policies"`
+	}{}
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("policy: invalid configuration: %w", err)
+	}
+	names := make([]string, 0, len(config.Policies))
+	for name := range config.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	set := &Set{policies: make(map[string]*Policy, len(names))}
+	var errs []string
+	for _, name := range names {
+		p, err := Compile(name, config.Policies[name])
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		set.policies[name] = p
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("policy: %d invalid policies:\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+	return set, nil
+}
+
+// LoadFile is similar as Load for the file name
+func LoadFile(name string) (*Set, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Load(file)
+}
+
+// Get return the policy name, ok is false when there is none
+func (s *Set) Get(name string) (p *Policy, ok bool) {
+	p, ok = s.policies[name]
+	return p, ok
+}
+
+// MustGet is similar as Get but panics when there is no policy name. It is
+// meant for the routes registered at startup, so a typo fails there and not
+// on the first request
+func (s *Set) MustGet(name string) *Policy {
+	p, ok := s.policies[name]
+	if !ok {
+		panic(fmt.Sprintf("policy: no policy %q", name))
+	}
+	return p
+}
+
+// Names return the names of the policies, sorted
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.policies))
+	for name := range s.policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,181 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+const ordersPolicy = `"admin" in claims["cognito:groups"] ||
+	("orders/write" in claims.scope.split(" ") && claims["custom:tenant"] == request.params.tenant)`
+
+func TestPolicy_Eval(t *testing.T) {
+	p, err := Compile("orders", ordersPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		claims  map[string]interface{}
+		params  map[string]string
+		want    bool
+		wantErr bool
+	}{
+		{
+			"should allow an admin",
+			map[string]interface{}{"cognito:groups": []interface{}{"admin"}},
+			map[string]string{"tenant": "acme"},
+			true, false,
+		},
+		{
+			"should allow the scope on its tenant",
+			map[string]interface{}{"cognito:groups": []interface{}{}, "scope": "orders/read orders/write", "custom:tenant": "acme"},
+			map[string]string{"tenant": "acme"},
+			true, false,
+		},
+		{
+			"should deny the scope on an other tenant",
+			map[string]interface{}{"cognito:groups": []interface{}{}, "scope": "orders/write", "custom:tenant": "acme"},
+			map[string]string{"tenant": "globex"},
+			false, false,
+		},
+		{
+			"should deny without the scope",
+			map[string]interface{}{"cognito:groups": []interface{}{"users"}, "scope": "orders/read", "custom:tenant": "acme"},
+			map[string]string{"tenant": "acme"},
+			false, false,
+		},
+		{
+			"should deny a missing claim",
+			map[string]interface{}{"scope": "orders/write"},
+			map[string]string{"tenant": "acme"},
+			false, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.Eval(context.Background(), tt.claims, Request{Params: tt.params})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Policy.Eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Policy.Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    string
+	}{
+		{"should compile a dyn expression", `claims.admin`, ""},
+		{"should compile the request", `request.method == "GET" && request.headers["x-env"] == "prod"`, ""},
+		{"should return error: syntax", `claims.scope ==`, "policy bad: ERROR: <input>:1:16: Syntax error"},
+		{"should return error: unknown variable", `token.sub == "x"`, "undeclared reference to 'token'"},
+		{"should return error: not a bool", `request.method + "x"`, "must return a bool, not string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile("bad", tt.expression)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Compile() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	set, err := Load(strings.NewReader(`{"policies": {
+		"admin": "\"admin\" in claims[\"cognito:groups\"]",
+		"read": "\"orders/read\" in claims.scope.split(\" \")"
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := set.Names(); strings.Join(got, ",") != "admin,read" {
+		t.Errorf("Set.Names() = %v", got)
+	}
+	if p, ok := set.Get("admin"); !ok || p.Name != "admin" {
+		t.Errorf("Set.Get(admin) = %v, %v", p, ok)
+	}
+	if p, ok := set.Get("unknown"); ok || p != nil {
+		t.Errorf("Set.Get(unknown) = %v, %v", p, ok)
+	}
+	if p := set.MustGet("read"); p.Name != "read" {
+		t.Errorf("Set.MustGet(read) = %v", p)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Set.MustGet(unknown) should panic")
+			}
+		}()
+		set.MustGet("unknown")
+	}()
+
+	_, err = Load(strings.NewReader(`{"policies": {"a": "claims.x ==", "b": "true", "c": "1"}}`))
+	if err == nil || !strings.Contains(err.Error(), "2 invalid policies") || !strings.Contains(err.Error(), "policy a:") || !strings.Contains(err.Error(), "policy c:") {
+		t.Errorf("Load() error = %v", err)
+	}
+	if _, err := Load(strings.NewReader(`{"policy": {}}`)); err == nil {
+		t.Error("Load() should reject an unknown field")
+	}
+}
+
+func TestPolicy_Middleware(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	p, err := Compile("orders", ordersPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs := &bytes.Buffer{}
+	p.Logger = slog.New(slog.NewTextHandler(logs, nil))
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("POST /tenants/{tenant}/orders", s.Auth().Middleware()(p.Middleware("tenant")(ok)))
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		wantCode int
+	}{
+		{"should allow the tenant", "/tenants/acme/orders", s.AccessToken(cognitotest.WithGroups(), cognitotest.WithClaim("scope", "orders/write"), cognitotest.WithClaim("custom:tenant", "acme")), http.StatusOK},
+		{"should forbid an other tenant", "/tenants/globex/orders", s.AccessToken(cognitotest.WithGroups(), cognitotest.WithClaim("scope", "orders/write"), cognitotest.WithClaim("custom:tenant", "acme")), http.StatusForbidden},
+		{"should allow an admin", "/tenants/globex/orders", s.AccessToken(cognitotest.WithGroups("admin")), http.StatusOK},
+		{"should forbid a missing claim", "/tenants/globex/orders", s.AccessToken(), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("Middleware() code = %v, want %v", w.Code, tt.wantCode)
+			}
+		})
+	}
+
+	if !strings.Contains(logs.String(), "policy: evaluation failed") || !strings.Contains(logs.String(), "policy=orders") {
+		t.Errorf("Middleware() did not log the evaluation error, logs %v", logs)
+	}
+
+	w := httptest.NewRecorder()
+	p.Middleware()(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Middleware() without claims code = %v, want 401", w.Code)
+	}
+}
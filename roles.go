@@ -0,0 +1,83 @@
+package cognito
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrNoRole is returned by EffectiveRole when the token has no preferred role
+	ErrNoRole = errors.New("no role in the token")
+	// ErrRoleNotAllowed is returned by EffectiveRole when the requested role
+	// is not one of the token roles
+	ErrRoleNotAllowed = errors.New("role is not in cognito:roles")
+)
+
+// Role is an IAM role of the cognito:roles claim
+type Role struct {
+	ARN       string
+	Partition string
+	AccountID string
+	// Path is the role path, "/" when the role has none
+	Path string
+	Name string
+}
+
+// ParseRoleARN split an IAM role ARN such as
+// arn:aws:iam::123456789012:role/path/RoleName
+func ParseRoleARN(arn string) (Role, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[1] == "" || parts[2] != "iam" || parts[4] == "" ||
+		!strings.HasPrefix(parts[5], "role/") {
+		return Role{}, fmt.Errorf("%q is not an IAM role ARN", arn)
+	}
+	resource := strings.TrimPrefix(parts[5], "role")
+	i := strings.LastIndex(resource, "/")
+	if resource[i+1:] == "" {
+		return Role{}, fmt.Errorf("%q has no role name", arn)
+	}
+	return Role{
+		ARN:       arn,
+		Partition: parts[1],
+		AccountID: parts[4],
+		Path:      resource[:i+1],
+		Name:      resource[i+1:],
+	}, nil
+}
+
+// ParsedRoles parse the ARNs of the cognito:roles claim
+func (p *IDTokenPayload) ParsedRoles() ([]Role, error) {
+	roles := make([]Role, 0, len(p.Roles))
+	for _, arn := range p.Roles {
+		role, err := ParseRoleARN(arn)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// EffectiveRole pick the role the same way an identity pool configured to
+// choose the role from the token does:
+//   - a requested role (customRoleArn) is used if it is one of cognito:roles,
+//     otherwise ErrRoleNotAllowed is returned
+//   - without requested role, cognito:preferred_role is used. cognito only set
+//     it when a single group has the lowest precedence
+//   - otherwise ErrNoRole is returned, the caller then apply its own
+//     resolution (default authenticated role or deny)
+//
+// see https://docs.aws.amazon.com/cognito/latest/developerguide/role-based-access-control.html
+func (p *IDTokenPayload) EffectiveRole(requested string) (Role, error) {
+	if requested != "" {
+		if !containsString(p.Roles, requested) {
+			return Role{}, fmt.Errorf("%w: %s", ErrRoleNotAllowed, requested)
+		}
+		return ParseRoleARN(requested)
+	}
+	if p.PreferredRole == "" {
+		return Role{}, ErrNoRole
+	}
+	return ParseRoleARN(p.PreferredRole)
+}
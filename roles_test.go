@@ -0,0 +1,104 @@
+package cognito
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestParseRoleARN(t *testing.T) {
+	tests := []struct {
+		name    string
+		arn     string
+		want    Role
+		wantErr bool
+	}{
+		{
+			"should parse a role without path",
+			"arn:aws:iam::123456789012:role/Admin",
+			Role{ARN: "arn:aws:iam::123456789012:role/Admin", Partition: "aws", AccountID: "123456789012", Path: "/", Name: "Admin"},
+			false,
+		},
+		{
+			"should parse a role with a path",
+			"arn:aws-cn:iam::123456789012:role/cognito/groups/Reader",
+			Role{ARN: "arn:aws-cn:iam::123456789012:role/cognito/groups/Reader", Partition: "aws-cn", AccountID: "123456789012", Path: "/cognito/groups/", Name: "Reader"},
+			false,
+		},
+		{"should fail on a user ARN", "arn:aws:iam::123456789012:user/marc", Role{}, true},
+		{"should fail on an other service", "arn:aws:s3:::bucket/role/x", Role{}, true},
+		{"should fail without account", "arn:aws:iam:::role/Admin", Role{}, true},
+		{"should fail without name", "arn:aws:iam::123456789012:role/", Role{}, true},
+		{"should fail on garbage", "Admin", Role{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoleARN(tt.arn)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRoleARN() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoleARN() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIDTokenPayload_EffectiveRole(t *testing.T) {
+	const (
+		admin  = "arn:aws:iam::123456789012:role/Admin"
+		reader = "arn:aws:iam::123456789012:role/Reader"
+	)
+	keys := mustParseKeys(map[string]*awsWellKnowKey{"RS256": testJWK("RS256", "RS256", &testRSAKey.PublicKey)})
+	claims := jwt.MapClaims{
+		"token_use":              "id",
+		"exp":                    9.56034296e+09,
+		"cognito:groups":         []string{"admin", "reader"},
+		"cognito:roles":          []string{admin, reader},
+		"cognito:preferred_role": admin,
+	}
+	aws := &Auth{awsKeys: keys}
+	payload, err := aws.ValidateIDToken(testSign(jwt.SigningMethodRS256, "RS256", testRSAKey, claims))
+	if err != nil {
+		t.Fatalf("Auth.ValidateIDToken() error = %v", err)
+	}
+	if !reflect.DeepEqual(payload.Groups, []string{"admin", "reader"}) || !reflect.DeepEqual(payload.Roles, []string{admin, reader}) {
+		t.Errorf("IDTokenPayload groups = %v, roles = %v", payload.Groups, payload.Roles)
+	}
+	roles, err := payload.ParsedRoles()
+	if err != nil || len(roles) != 2 || roles[1].Name != "Reader" {
+		t.Errorf("IDTokenPayload.ParsedRoles() = %v, %v", roles, err)
+	}
+	noPreferred := *payload
+	noPreferred.PreferredRole = ""
+
+	tests := []struct {
+		name      string
+		payload   *IDTokenPayload
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{"should use the preferred role", payload, "", "Admin", nil},
+		{"should use the requested role", payload, reader, "Reader", nil},
+		{"should refuse a role that is not in the token", payload, "arn:aws:iam::123456789012:role/Other", "", ErrRoleNotAllowed},
+		{"should fail without preferred role", &noPreferred, "", "", ErrNoRole},
+		{"should use the requested role without preferred role", &noPreferred, reader, "Reader", nil},
+		{"should fail without roles", &IDTokenPayload{}, "", "", ErrNoRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.payload.EffectiveRole(tt.requested)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("IDTokenPayload.EffectiveRole() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.Name != tt.want {
+				t.Errorf("IDTokenPayload.EffectiveRole() = %v, want %v", got.Name, tt.want)
+			}
+		})
+	}
+}
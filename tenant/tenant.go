@@ -0,0 +1,165 @@
+// Package tenant resolves the tenant of a user from the claims of its token,
+// for multi-tenant applications where a user pool is shared by the tenants.
+//
+// The tenant can come from a custom attribute, like custom:tenant_id, or from
+// the cognito groups with a prefix, like tenant-acme. The groups are in both
+// the id and the access tokens, while the custom attributes are only in the
+// id token unless a pre token generation trigger adds them to the access token.
+// The Middleware stores the tenant in the request context and rejects the
+// requests for an other tenant.
+//
+// Security: the tenant is only as trustworthy as the claim it comes from. A
+// custom attribute like custom:tenant_id must NOT be writable by the app
+// client, otherwise a user can change their own tenant with UpdateUserAttributes
+// and their access token, and read the data of any tenant. Remove the attribute
+// from the write attributes of every app client, and only set it from an
+// admin API or a trigger. The groups can only be changed by an administrator.
+// see https://docs.aws.amazon.com/cognito/latest/developerguide/user-pool-settings-attributes.html#user-pool-settings-attribute-permissions-and-scopes
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	cognito "github.com/AyWa/jwt-cognito"
+)
+
+// Errors returned by the resolution of the tenant
+var (
+	// ErrNoTenant is returned when no strategy found a tenant
+	ErrNoTenant = errors.New("tenant: no tenant")
+	// ErrAmbiguousTenant is returned when the user is in several tenant groups
+	ErrAmbiguousTenant = errors.New("tenant: several tenants")
+	// ErrTenantMismatch is returned when the tenant of the route is not the
+	// tenant of the user
+	ErrTenantMismatch = errors.New("tenant: tenant does not match")
+)
+
+// DefaultClaim is the custom attribute usually holding the tenant, it must be
+// read only for the app clients
+const DefaultClaim = "custom:tenant_id"
+
+// Strategy extract the tenant from the claims, it returns "" when the claims
+// have no tenant for this strategy
+type Strategy func(claims map[string]interface{}) (string, error)
+
+// Claim read the tenant from a string claim, usually a custom attribute. The
+// attribute must be read only for the app clients, see the package doc
+func Claim(name string) Strategy {
+	return func(claims map[string]interface{}) (string, error) {
+		tenant, _ := claims[name].(string)
+		return tenant, nil
+	}
+}
+
+// GroupPrefix read the tenant from the cognito:groups starting with prefix,
+// the group tenant-acme is the tenant acme for the prefix "tenant-".
+// A user in several of these groups is rejected with ErrAmbiguousTenant
+func GroupPrefix(prefix string) Strategy {
+	return func(claims map[string]interface{}) (string, error) {
+		groups, _ := claims["cognito:groups"].([]interface{})
+		var tenant string
+		for _, g := range groups {
+			group, _ := g.(string)
+			if !strings.HasPrefix(group, prefix) || len(group) == len(prefix) {
+				continue
+			}
+			if tenant != "" && tenant != group[len(prefix):] {
+				return "", ErrAmbiguousTenant
+			}
+			tenant = group[len(prefix):]
+		}
+		return tenant, nil
+	}
+}
+
+// Resolver find the tenant of the claims with the first strategy that
+// returns one
+type Resolver struct {
+	strategies []Strategy
+}
+
+// New create a resolver trying strategy then the others in order. There is
+// no default, the claim holding the tenant is a security decision of the
+// application
+func New(strategy Strategy, others ...Strategy) *Resolver {
+	return &Resolver{strategies: append([]Strategy{strategy}, others...)}
+}
+
+// Resolve return the tenant of the claims
+func (res *Resolver) Resolve(claims map[string]interface{}) (string, error) {
+	for _, strategy := range res.strategies {
+		tenant, err := strategy(claims)
+		if err != nil {
+			return "", err
+		}
+		if tenant != "" {
+			return tenant, nil
+		}
+	}
+	return "", ErrNoTenant
+}
+
+// Check return the tenant of the claims, ErrTenantMismatch when it is not
+// routeTenant. An empty routeTenant matches any tenant
+func (res *Resolver) Check(claims map[string]interface{}, routeTenant string) (string, error) {
+	tenant, err := res.Resolve(claims)
+	if err != nil {
+		return "", err
+	}
+	if routeTenant != "" && routeTenant != tenant {
+		return "", fmt.Errorf("%w: %s is not %s", ErrTenantMismatch, routeTenant, tenant)
+	}
+	return tenant, nil
+}
+
+type contextKey struct{}
+
+// Middleware return an http middleware that stores the tenant of the user
+// in the request context, see FromContext. It must be used after the cognito
+// Middleware, that stores the claims.
+// param is the name of the path wildcard of the route holding the tenant, see
+// http.Request.PathValue, or empty for a route without tenant. A request
+// for an other tenant, or from a user without tenant, is rejected with a 403.
+// So is a request whose route has no value for param, for example behind a
+// router that does not set the path values: the route tenant is never skipped
+func (res *Resolver) Middleware(param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := cognito.ClaimsFromContext(r.Context())
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			var routeTenant string
+			if param != "" {
+				routeTenant = r.PathValue(param)
+				if routeTenant == "" {
+					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					return
+				}
+			}
+			tenant, err := res.Check(claims, routeTenant)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), tenant)))
+		})
+	}
+}
+
+// NewContext return a copy of ctx holding the tenant
+func NewContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext return the tenant stored by the Middleware
+func FromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(contextKey{}).(string)
+	return tenant, ok
+}
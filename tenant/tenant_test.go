@@ -0,0 +1,89 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AyWa/jwt-cognito/cognitotest"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+	groups := func(g ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"cognito:groups": g}
+	}
+	tests := []struct {
+		name     string
+		resolver *Resolver
+		claims   map[string]interface{}
+		want     string
+		wantErr  error
+	}{
+		{"should read the default claim", New(Claim(DefaultClaim)), map[string]interface{}{"custom:tenant_id": "acme"}, "acme", nil},
+		{"should read an other claim", New(Claim("custom:org")), map[string]interface{}{"custom:org": "acme"}, "acme", nil},
+		{"should read the group prefix", New(GroupPrefix("tenant-")), groups("users", "tenant-acme"), "acme", nil},
+		{"should ignore the prefix alone", New(GroupPrefix("tenant-")), groups("tenant-"), "", ErrNoTenant},
+		{"should reject several tenant groups", New(GroupPrefix("tenant-")), groups("tenant-acme", "tenant-globex"), "", ErrAmbiguousTenant},
+		{"should use the first strategy with a tenant", New(Claim(DefaultClaim), GroupPrefix("tenant-")), groups("tenant-acme"), "acme", nil},
+		{"should return error: no tenant", New(Claim(DefaultClaim), GroupPrefix("tenant-")), groups("users"), "", ErrNoTenant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.resolver.Resolve(tt.claims)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Resolver.Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Resolver.Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolver_Middleware(t *testing.T) {
+	s := cognitotest.NewServer()
+	defer s.Close()
+	res := New(Claim(DefaultClaim), GroupPrefix("tenant-"))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := FromContext(r.Context())
+		if !ok {
+			t.Error("FromContext() found no tenant")
+		}
+		w.Write([]byte(tenant))
+	})
+	mux := http.NewServeMux()
+	mux.Handle("GET /tenants/{tenant}/orders", s.Auth().Middleware()(res.Middleware("tenant")(handler)))
+	mux.Handle("GET /me", s.Auth().Middleware()(res.Middleware("")(handler)))
+	mux.Handle("GET /tenants/{tenant}/invoices", s.Auth().Middleware()(res.Middleware("tenant_id")(handler)))
+	mux.Handle("GET /orders", s.Auth().Middleware()(res.Middleware("tenant")(handler)))
+	tests := []struct {
+		name     string
+		path     string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{"should allow the tenant of the claim", "/tenants/acme/orders", s.IDToken(cognitotest.WithClaim(DefaultClaim, "acme")), http.StatusOK, "acme"},
+		{"should allow the tenant of the group", "/tenants/acme/orders", s.AccessToken(cognitotest.WithGroups("tenant-acme")), http.StatusOK, "acme"},
+		{"should forbid an other tenant", "/tenants/globex/orders", s.AccessToken(cognitotest.WithGroups("tenant-acme")), http.StatusForbidden, ""},
+		{"should forbid a user without tenant", "/tenants/acme/orders", s.AccessToken(), http.StatusForbidden, ""},
+		{"should resolve the tenant of a route without tenant", "/me", s.IDToken(cognitotest.WithClaim(DefaultClaim, "acme")), http.StatusOK, "acme"},
+		{"should forbid a route without the param", "/tenants/acme/invoices", s.IDToken(cognitotest.WithClaim(DefaultClaim, "acme")), http.StatusForbidden, ""},
+		{"should forbid a route without wildcard", "/orders", s.IDToken(cognitotest.WithClaim(DefaultClaim, "acme")), http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Middleware() code = %v, want %v", w.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusOK && w.Body.String() != tt.wantBody {
+				t.Errorf("Middleware() tenant = %v, want %v", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
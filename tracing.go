@@ -0,0 +1,34 @@
+package cognito
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/AyWa/jwt-cognito"
+
+// attributes of the spans, the kid comes from the token header so it is
+// set even when the token is not valid
+var (
+	attrKid      = attribute.Key("cognito.kid")
+	attrTokenUse = attribute.Key("cognito.token_use")
+	attrOutcome  = attribute.Key("cognito.outcome")
+	attrCacheHit = attribute.Key("cognito.cache_hit")
+)
+
+func (aws *Auth) tracer() trace.Tracer {
+	if aws.tracerProvider != nil {
+		return aws.tracerProvider.Tracer(instrumentationName)
+	}
+	return noop.NewTracerProvider().Tracer(instrumentationName)
+}
+
+// recordError mark the span as failed, the span is still ended by its creator
+func recordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
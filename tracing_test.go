@@ -0,0 +1,82 @@
+package cognito
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestAuth_ValidateTokenContext_tracing(t *testing.T) {
+	srv, _ := newTestIssuer(t, nil, testJWK("kid-rsa", "RS256", &testRSAKey.PublicKey))
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	aws := New("", "", WithJWKSURL(srv.URL+"/jwks"), WithTracerProvider(tp))
+	validToken := testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, jwt.MapClaims{"token_use": "access", "exp": 9.56034296e+09})
+	expiredToken := testSign(jwt.SigningMethodRS256, "kid-rsa", testRSAKey, jwt.MapClaims{"token_use": "id", "exp": 1})
+
+	tests := []struct {
+		name         string
+		token        string
+		wantSpans    []string
+		wantOutcome  string
+		wantTokenUse string
+		wantCacheHit bool
+		wantStatus   codes.Code
+	}{
+		{"first validation fetch the keys", validToken, []string{"cognito.fetchKeys", "cognito.ValidateToken"}, "valid", "access", false, codes.Unset},
+		{"second validation use the cache", validToken, []string{"cognito.ValidateToken"}, "valid", "access", true, codes.Unset},
+		{"failed validation", expiredToken, []string{"cognito.ValidateToken"}, "expired", "id", true, codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter.Reset()
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+			aws.ValidateTokenContext(ctx, tt.token)
+			parent.End()
+
+			spans := exporter.GetSpans()
+			// the parent span is the last one
+			if len(spans) != len(tt.wantSpans)+1 {
+				t.Fatalf("got %v spans, want %v", len(spans), len(tt.wantSpans)+1)
+			}
+			for i, name := range tt.wantSpans {
+				if spans[i].Name != name {
+					t.Errorf("span %v = %v, want %v", i, spans[i].Name, name)
+				}
+			}
+			validate := spans[len(tt.wantSpans)-1]
+			if validate.Parent.SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("cognito.ValidateToken is not a child of the caller span")
+			}
+			attrs := spanAttributes(validate)
+			if got := attrs[attrKid].AsString(); got != "kid-rsa" {
+				t.Errorf("cognito.kid = %v, want kid-rsa", got)
+			}
+			if got := attrs[attrOutcome].AsString(); got != tt.wantOutcome {
+				t.Errorf("cognito.outcome = %v, want %v", got, tt.wantOutcome)
+			}
+			if got := attrs[attrTokenUse].AsString(); got != tt.wantTokenUse {
+				t.Errorf("cognito.token_use = %v, want %v", got, tt.wantTokenUse)
+			}
+			if got := attrs[attrCacheHit].AsBool(); got != tt.wantCacheHit {
+				t.Errorf("cognito.cache_hit = %v, want %v", got, tt.wantCacheHit)
+			}
+			if validate.Status.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", validate.Status.Code, tt.wantStatus)
+			}
+		})
+	}
+}